@@ -9,6 +9,9 @@ import (
 	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
 	"github.com/himanshu3889/discore-backend/base/models"
 	accountStore "github.com/himanshu3889/discore-backend/base/store/account"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
 )
 
 // Create user by write back cache strategy
@@ -35,3 +38,13 @@ func CreateUser(ctx context.Context, user *models.User) *appError.Error {
 
 	return nil
 }
+
+// Invalidate the cached user session; signed out or revoked
+func InvalidateUserSession(ctx context.Context, sessionID snowflake.ID) *appError.Error {
+	cacheKey, _ := rediskeys.Keys.User.Session(sessionID)
+	if err := redisDatabase.GlobalCacheManager.Delete(ctx, cacheKey); err != nil {
+		logrus.WithField("session_id", sessionID).WithError(err).Error("Failed to invalidate the user session cache")
+		return appError.NewInternal("Failed to invalidate the user session cache")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package accountCacheStore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+	accountStore "github.com/himanshu3889/discore-backend/base/store/account"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Expiry or missed signout invalidation of the session is seen within the ttl
+const sessionCacheTTL = time.Minute
+
+// Check the user session is not signed out or expired; only the active sessions are cached
+func HasUserSession(ctx context.Context, sessionID snowflake.ID, userID snowflake.ID) (bool, *appError.Error) {
+	cacheKey, cacheBoundedKey := rediskeys.Keys.User.Session(sessionID)
+	userBytes, _ := redisDatabase.GlobalCacheManager.Get(ctx, cacheBoundedKey, cacheKey, nil, nil)
+	if userBytes != nil {
+		return string(userBytes) == strconv.FormatInt(userID.Int64(), 10), nil
+	}
+
+	active, appErr := accountStore.HasUserSession(ctx, sessionID, userID)
+	if appErr != nil || !active {
+		return false, appErr
+	}
+	redisDatabase.GlobalCacheManager.Set(ctx, cacheKey, nil, userID.Int64(), nil, sessionCacheTTL)
+	return true, nil
+}
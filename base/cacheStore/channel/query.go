@@ -1,6 +1,10 @@
 package channelCacheStore
 
 import (
+	"context"
+	"encoding/json"
+	"time"
+
 	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
 	"github.com/himanshu3889/discore-backend/base/infrastructure/redis/bloomFilter"
 	"github.com/himanshu3889/discore-backend/base/lib/appError"
@@ -9,18 +13,26 @@ import (
 	channelStore "github.com/himanshu3889/discore-backend/base/store/channel"
 
 	"github.com/bwmarrin/snowflake"
-	"github.com/gin-gonic/gin"
 )
 
-// Get channel by ID; using cache
-func GetChannelByID(ctx *gin.Context, channelID snowflake.ID) (*models.Channel, *appError.Error) {
+// Get channel by ID; using cache, database on the miss
+func GetChannelByID(ctx context.Context, channelID snowflake.ID) (*models.Channel, *appError.Error) {
 	channelCacheKey, cacheBoundedKey := rediskeys.Keys.Channel.Info(channelID)
 	channelBloomKey := bloomFilter.ChannelIDBloomFilter
 	bloomItem := channelID.String()
-	serverBytes, _ := redisDatabase.GlobalCacheManager.Get(ctx, cacheBoundedKey, channelCacheKey, &channelBloomKey, &bloomItem)
-	if serverBytes == nil {
-		// Channel does not exist
-		return nil, appError.NewNotFound("Channel does not exist")
+	channelBytes, _ := redisDatabase.GlobalCacheManager.Get(ctx, cacheBoundedKey, channelCacheKey, &channelBloomKey, &bloomItem)
+	if channelBytes != nil {
+		var channel models.Channel
+		if err := json.Unmarshal(channelBytes, &channel); err == nil {
+			return &channel, nil
+		}
+	}
+
+	// Expired, deleted or never cached
+	channel, appErr := channelStore.GetChannelByID(ctx, channelID)
+	if appErr != nil {
+		return nil, appErr
 	}
-	return channelStore.GetChannelByID(ctx, channelID)
+	redisDatabase.GlobalCacheManager.Set(ctx, channelCacheKey, &channelBloomKey, channel, &bloomItem, 14*24*time.Hour)
+	return channel, nil
 }
@@ -0,0 +1,28 @@
+package memberCacheStore
+
+import "github.com/redis/go-redis/v9"
+
+// Invalidate the cached member; tombstone blocks the repopulation by the reads started before the write
+var invalidateMemberScript = redis.NewScript(`
+	local cacheKey = KEYS[1]
+	local tombstoneKey = KEYS[2]
+	local tombstoneTTL = tonumber(ARGV[1])
+
+	redis.call("DEL", cacheKey)
+	redis.call("SET", tombstoneKey, "1", "PX", tombstoneTTL)
+	return 1
+`)
+
+// Cache the member read from the database unless invalidated meanwhile
+var cacheMemberScript = redis.NewScript(`
+	local cacheKey = KEYS[1]
+	local tombstoneKey = KEYS[2]
+	local member = ARGV[1]
+	local ttl = tonumber(ARGV[2])
+
+	if redis.call("EXISTS", tombstoneKey) == 1 then
+		return 0
+	end
+	redis.call("SET", cacheKey, member, "PX", ttl)
+	return 1
+`)
@@ -0,0 +1,35 @@
+package memberCacheStore
+
+import (
+	"context"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	memberCacheTTL = 5 * time.Minute  // role change or removal is seen within the ttl if the invalidation is missed
+	tombstoneTTL   = 10 * time.Second // longer than the member read of the database
+)
+
+// Invalidate the cached server member; e.g. joined, role changed, kicked or left.
+// Not cached again until the tombstone expires so a read started before the write can't repopulate it
+func InvalidateServerMember(ctx context.Context, userID snowflake.ID, serverID snowflake.ID) *appError.Error {
+	cacheKey, _ := rediskeys.Keys.Member.Info(serverID, userID)
+	tombstoneKey, boundedKey := rediskeys.Keys.Member.Tombstone(serverID, userID)
+	_, err := redisDatabase.GlobalCacheManager.RunScript(ctx, boundedKey, invalidateMemberScript,
+		[]string{cacheKey, tombstoneKey}, tombstoneTTL.Milliseconds())
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"user_id":   userID,
+			"server_id": serverID,
+		}).WithError(err).Error("Failed to invalidate the server member cache")
+		return appError.NewInternal("Failed to invalidate the server member cache")
+	}
+	return nil
+}
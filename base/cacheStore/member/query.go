@@ -2,12 +2,14 @@ package memberCacheStore
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
 	"github.com/himanshu3889/discore-backend/base/infrastructure/redis/bloomFilter"
 	"github.com/himanshu3889/discore-backend/base/lib/appError"
 	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+	"github.com/himanshu3889/discore-backend/base/models"
 	serverStore "github.com/himanshu3889/discore-backend/base/store/server"
 
 	"github.com/bwmarrin/snowflake"
@@ -35,3 +37,42 @@ func HasUserServerMember(ctx context.Context, userID snowflake.ID, serverID snow
 
 	return serverStore.HasUserServerMember(ctx, userID, serverID)
 }
+
+// Get the active server member of the user; using cache. Nil if not a member
+func GetActiveServerMember(ctx context.Context, userID snowflake.ID, serverID snowflake.ID) (*models.Member, *appError.Error) {
+	cacheKey, cacheBoundedKey := rediskeys.Keys.Member.Info(serverID, userID)
+	memberBytes, _ := redisDatabase.GlobalCacheManager.Get(ctx, cacheBoundedKey, cacheKey, nil, nil)
+	if memberBytes != nil {
+		var member models.Member
+		if err := json.Unmarshal(memberBytes, &member); err == nil {
+			return &member, nil
+		}
+	}
+
+	// Only the active members are cached; a joined user is never denied by the stale cache
+	member, appErr := GetActiveServerMemberUncached(ctx, userID, serverID)
+	if appErr != nil || member == nil {
+		return nil, appErr
+	}
+	if memberBytes, err := json.Marshal(member); err == nil {
+		tombstoneKey, _ := rediskeys.Keys.Member.Tombstone(serverID, userID)
+		redisDatabase.GlobalCacheManager.RunScript(ctx, cacheBoundedKey, cacheMemberScript,
+			[]string{cacheKey, tombstoneKey}, memberBytes, memberCacheTTL.Milliseconds())
+	}
+	return member, nil
+}
+
+// Get the active server member of the user from the database; for the role gated writes. Nil if not a member
+func GetActiveServerMemberUncached(ctx context.Context, userID snowflake.ID, serverID snowflake.ID) (*models.Member, *appError.Error) {
+	member, appErr := serverStore.GetUserServerMemember(ctx, userID, serverID)
+	if appErr != nil {
+		if appErr.Code == appError.StatusNotFound {
+			return nil, nil
+		}
+		return nil, appErr
+	}
+	if member.DeletedAt != nil {
+		return nil, nil
+	}
+	return member, nil
+}
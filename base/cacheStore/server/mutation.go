@@ -181,6 +181,7 @@ func AcceptServerInviteAndCreateMember(ctx context.Context, userID snowflake.ID,
 		serverInviteLib.RollbackConsumeServerInviteCache(ctx, serverInvite)
 		return nil, appErr
 	}
+	memberCacheStore.InvalidateServerMember(ctx, userID, serverInvite.ServerID)
 
 	return serverInvite, appErr
 }
@@ -12,10 +12,16 @@ END $$;
 GRANT CONNECT ON DATABASE discore TO discore;
 GRANT USAGE ON SCHEMA public TO discore;
 GRANT SELECT ON public.members TO discore;
+GRANT SELECT ON public.channels TO discore;
+GRANT SELECT ON public.conversations TO discore;
+GRANT SELECT ON public.user_sessions TO discore;
 ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT ON TABLES TO discore;
 
 -- Replica identity (idempotent)
 ALTER TABLE public.members REPLICA IDENTITY FULL;
+ALTER TABLE public.channels REPLICA IDENTITY FULL;
+ALTER TABLE public.conversations REPLICA IDENTITY FULL;
+-- user_sessions keeps the default identity; deletes publish only the id, never the refresh token
 
 
 -- Create publication only if not exists
@@ -24,6 +30,39 @@ BEGIN
     IF NOT EXISTS (
         SELECT 1 FROM pg_publication WHERE pubname = 'discore_publication'
     ) THEN
-        CREATE PUBLICATION discore_publication FOR TABLE public.members;
+        CREATE PUBLICATION discore_publication FOR TABLE public.members, public.channels, public.conversations, public.user_sessions;
+    END IF;
+END $$;
+
+-- Add channels to the publication created before (idempotent)
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_publication_tables
+        WHERE pubname = 'discore_publication' AND schemaname = 'public' AND tablename = 'channels'
+    ) THEN
+        ALTER PUBLICATION discore_publication ADD TABLE public.channels;
+    END IF;
+END $$;
+
+-- Add conversations to the publication created before (idempotent)
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_publication_tables
+        WHERE pubname = 'discore_publication' AND schemaname = 'public' AND tablename = 'conversations'
+    ) THEN
+        ALTER PUBLICATION discore_publication ADD TABLE public.conversations;
+    END IF;
+END $$;
+
+-- Add user_sessions to the publication created before (idempotent)
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_publication_tables
+        WHERE pubname = 'discore_publication' AND schemaname = 'public' AND tablename = 'user_sessions'
+    ) THEN
+        ALTER PUBLICATION discore_publication ADD TABLE public.user_sessions;
     END IF;
 END $$;
@@ -39,7 +39,8 @@ func InitMongoDB() {
 		host := configs.Config.MONGODB_HOST
 		database := configs.Config.MONGODB_DATABASE
 
-		uri := fmt.Sprintf("mongodb://%s:%s@%s:27017/%s?authSource=%s",
+		// Direct connection to the replica set primary; the advertised host may not resolve outside the network
+		uri := fmt.Sprintf("mongodb://%s:%s@%s:27017/%s?authSource=%s&directConnection=true",
 			username, password, host, database, database)
 
 		logrus.Info(uri)
@@ -84,6 +85,32 @@ func createIndexes(ctx context.Context) {
 				{"_id", -1},       // Sort: matches descending sort + range query
 			},
 		},
+		{
+			// Thread replies of the parent
+			Keys: bson.D{
+				{"referenced_message_id", 1},
+				{"deleted", 1},
+				{"_id", -1},
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"referenced_message_id": bson.M{"$exists": true}}),
+		},
+		{
+			// Messages mentioning the user
+			Keys: bson.D{
+				{"mentions.user_ids", 1},
+				{"deleted", 1},
+				{"_id", -1},
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"mentions.user_ids": bson.M{"$exists": true}}),
+		},
+		{
+			// Pins of the channel
+			Keys: bson.D{
+				{"channel_id", 1},
+				{"pinned_at", -1},
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"pinned_at": bson.M{"$exists": true}}),
+		},
 		// {
 		// 	// Channel + Member queries
 		// 	Keys: bson.D{
@@ -104,6 +131,56 @@ func createIndexes(ctx context.Context) {
 				{"_id", -1},            // Sort: matches descending sort + range query
 			},
 		},
+		{
+			// Pins of the conversation
+			Keys: bson.D{
+				{"conversation_id", 1},
+				{"pinned_at", -1},
+			},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"pinned_at": bson.M{"$exists": true}}),
+		},
+	})
+
+	// Reaction indexes of the channel and direct messages
+	MongoDB.Collection("message_reactions").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// One reaction of the user per emoji
+			Keys: bson.D{
+				{"message_id", 1},
+				{"emoji", 1},
+				{"user_id", 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Reactions of the user on the fetched messages
+			Keys: bson.D{
+				{"user_id", 1},
+				{"message_id", 1},
+			},
+		},
+	})
+
+	// Read states of the users
+	MongoDB.Collection("read_states").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// One state per user and channel or conversation
+			Keys: bson.D{
+				{"user_id", 1},
+				{"target_id", 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+}
+
+// Run the function in a mongo transaction; the driver retries it on the transient errors
+func WithMongoTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	return MongoClient.UseSession(ctx, func(sessCtx mongo.SessionContext) error {
+		_, err := sessCtx.WithTransaction(sessCtx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sessCtx)
+		})
+		return err
 	})
 }
 
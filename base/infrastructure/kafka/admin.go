@@ -0,0 +1,39 @@
+package baseKafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Delete the consumer groups from the kafka; used to cleanup the ephemeral groups
+func DeleteConsumerGroups(ctx context.Context, brokers []string, groupIDs []string) error {
+	if len(brokers) == 0 || len(groupIDs) == 0 {
+		return nil
+	}
+
+	client := &kafka.Client{
+		Addr:    kafka.TCP(brokers...),
+		Timeout: 10 * time.Second,
+	}
+
+	var errs []error
+	// Groups can live on different coordinators; so delete one by one
+	for _, groupID := range groupIDs {
+		resp, err := client.DeleteGroups(ctx, &kafka.DeleteGroupsRequest{
+			GroupIDs: []string{groupID},
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if groupErr := resp.Errors[groupID]; groupErr != nil {
+			errs = append(errs, fmt.Errorf("delete consumer group `%s`: %w", groupID, groupErr))
+		}
+	}
+
+	return errors.Join(errs...)
+}
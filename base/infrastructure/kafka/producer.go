@@ -4,6 +4,9 @@ package baseKafka
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	baseMetrics "github.com/himanshu3889/discore-backend/base/metric"
@@ -17,13 +20,19 @@ import (
 // Producer sends any struct to any topic
 type KafkaProducer struct {
 	writer *kafka.Writer
+
+	deliveries  sync.Map // delivery id -> func(error); callbacks of the tracked sends
+	deliverySeq atomic.Uint64
 }
 
+// Header of the tracked send; matched back in the writer completion
+const deliveryHeader = "delivery_id"
+
 // TODO: implement the error logger here and metric for that also
 
 // New kafka producer
 func NewProducer(brokers []string) *KafkaProducer {
-	return &KafkaProducer{
+	producer := &KafkaProducer{
 		writer: &kafka.Writer{
 			Addr:     kafka.TCP(brokers...),
 			Balancer: &kafka.Hash{}, // Keep this - ensures same key → same partition
@@ -54,6 +63,10 @@ func NewProducer(brokers []string) *KafkaProducer {
 			}),
 		},
 	}
+
+	// Async writes report here once kafka has taken them or failed
+	producer.writer.Completion = producer.onCompletion
+	return producer
 }
 
 // Write bulk messages to kafka
@@ -116,6 +129,34 @@ func (p *KafkaProducer) Send(ctx context.Context, topic, key string, data []byte
 	})
 }
 
+// Send and get notified once kafka has taken the message or failed; writer is async so Send returns early
+func (p *KafkaProducer) SendTracked(ctx context.Context, topic, key string, data []byte, userID snowflake.ID, onDelivered func(error), extraHeaders ...kafka.Header) error {
+	deliveryID := strconv.FormatUint(p.deliverySeq.Add(1), 10)
+	p.deliveries.Store(deliveryID, onDelivered)
+
+	headers := append(extraHeaders[:len(extraHeaders):len(extraHeaders)], kafka.Header{Key: deliveryHeader, Value: []byte(deliveryID)})
+	if err := p.Send(ctx, topic, key, data, userID, headers...); err != nil {
+		p.deliveries.Delete(deliveryID) // Never reaches the completion
+		return err
+	}
+	return nil
+}
+
+// Writer completion; call back the tracked sends of the written batch
+func (p *KafkaProducer) onCompletion(messages []kafka.Message, err error) {
+	for _, msg := range messages {
+		for _, h := range msg.Headers {
+			if h.Key != deliveryHeader {
+				continue
+			}
+			if onDelivered, ok := p.deliveries.LoadAndDelete(string(h.Value)); ok {
+				onDelivered.(func(error))(err)
+			}
+			break
+		}
+	}
+}
+
 // Close kafka producer
 func (p *KafkaProducer) Close() error {
 	return p.writer.Close()
@@ -0,0 +1,70 @@
+package accessLib
+
+import (
+	"context"
+
+	channelCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/channel"
+	memberCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/member"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Active member of the server; forbidden if not a member
+func ServerMember(ctx context.Context, userID, serverID snowflake.ID) (*models.Member, *appError.Error) {
+	member, appErr := memberCacheStore.GetActiveServerMember(ctx, userID, serverID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if member == nil {
+		return nil, appError.NewForbidden("Not a member of the server")
+	}
+	return member, nil
+}
+
+// Active member of the server read past the cache; for the role gated writes. Forbidden if not a member
+func CurrentServerMember(ctx context.Context, userID, serverID snowflake.ID) (*models.Member, *appError.Error) {
+	member, appErr := memberCacheStore.GetActiveServerMemberUncached(ctx, userID, serverID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if member == nil {
+		return nil, appError.NewForbidden("Not a member of the server")
+	}
+	return member, nil
+}
+
+// Can the user read the server channels; false without the error if not a member
+func CanReadServer(ctx context.Context, userID, serverID snowflake.ID) (bool, *appError.Error) {
+	member, appErr := memberCacheStore.GetActiveServerMember(ctx, userID, serverID)
+	if appErr != nil {
+		return false, appErr
+	}
+	return member != nil, nil
+}
+
+// Channel readable by the user with the membership; not found if the channel is deleted, forbidden if not a member
+func ReadableChannel(ctx context.Context, userID, channelID snowflake.ID) (*models.Channel, *models.Member, *appError.Error) {
+	channel, appErr := channelCacheStore.GetChannelByID(ctx, channelID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	member, appErr := ServerMember(ctx, userID, channel.ServerID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	return channel, member, nil
+}
+
+// Channel of the server readable by the user; not found if the channel is of other server
+func ReadableServerChannel(ctx context.Context, userID, serverID, channelID snowflake.ID) (*models.Channel, *models.Member, *appError.Error) {
+	channel, member, appErr := ReadableChannel(ctx, userID, channelID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	if channel.ServerID != serverID {
+		return nil, nil, appError.NewNotFound("Channel not found")
+	}
+	return channel, member, nil
+}
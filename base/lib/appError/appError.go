@@ -28,6 +28,14 @@ func NewBadRequest(message string) *Error {
 	}
 }
 
+// enforces a 403 status
+func NewForbidden(message string) *Error {
+	return &Error{
+		Code:    StatusForbidden,
+		Message: message,
+	}
+}
+
 // enforces a 404 status
 func NewNotFound(message string) *Error {
 	return &Error{
@@ -0,0 +1,60 @@
+package broadcastLib
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Header of the per-room sequence; clients resume the room after the last seen sequence
+const RoomSeqHeader = "room_seq"
+
+const (
+	roomSeqTimeout = 500 * time.Millisecond
+	// Counter of the idle room expires; far longer than the socket resume window, a restarted counter asks the resuming clients to resync
+	roomSeqTTL = 24 * time.Hour
+)
+
+// Broadcast topic of the room event; consumed by every websocket hub
+func Topic(event string) string {
+	return "broadcast." + event
+}
+
+// Next sequence of the room event; 0 if sequencing failed. Sent as is so the hubs know the room history has a hole
+func NextRoomSeq(ctx context.Context, room string) uint64 {
+	ctx, cancel := context.WithTimeout(ctx, roomSeqTimeout)
+	defer cancel()
+
+	seqKey, _ := rediskeys.Keys.Websocket.RoomSeq(room)
+	var incr *redis.IntCmd
+	_, err := redisDatabase.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, seqKey)
+		pipe.Expire(ctx, seqKey, roomSeqTTL)
+		return nil
+	})
+	seq := incr.Val()
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to sequence room %s event", room)
+		return 0 // Lost seq; resume across it asks the client to resync
+	}
+	return uint64(seq)
+}
+
+// Kafka header of the room sequence
+func RoomSeqKafkaHeader(seq uint64) kafka.Header {
+	return kafka.Header{Key: RoomSeqHeader, Value: []byte(strconv.FormatUint(seq, 10))}
+}
+
+// Publish the sequenced room event to the hubs; e.g. message edited from the rest api
+func Publish(ctx context.Context, producer *baseKafka.KafkaProducer, event string, room string, data []byte, userID snowflake.ID) error {
+	return producer.Send(ctx, Topic(event), room, data, userID, RoomSeqKafkaHeader(NextRoomSeq(ctx, room)))
+}
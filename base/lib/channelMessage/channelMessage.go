@@ -0,0 +1,217 @@
+package channelMessageLib
+
+import (
+	"context"
+	"fmt"
+
+	accessLib "github.com/himanshu3889/discore-backend/base/lib/access"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	mentionLib "github.com/himanshu3889/discore-backend/base/lib/mention"
+	reactionLib "github.com/himanshu3889/discore-backend/base/lib/reaction"
+	"github.com/himanshu3889/discore-backend/base/models"
+	channelMessageStore "github.com/himanshu3889/discore-backend/base/store/channelMessage"
+	reactionStore "github.com/himanshu3889/discore-backend/base/store/reaction"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+)
+
+// Deleted message broadcast to the server room; parent set for the deleted reply
+type DeletedMessage struct {
+	ID                  snowflake.ID  `json:"id"`
+	ServerID            snowflake.ID  `json:"serverID"`
+	ChannelID           snowflake.ID  `json:"channelID"`
+	DeletedBy           snowflake.ID  `json:"deletedBy"`
+	ReferencedMessageID *snowflake.ID `json:"referencedMessageID,omitempty"`
+	Unpinned            *PinsUpdate   `json:"-"` // pin change if the deleted message was pinned
+}
+
+// Max pinned messages of the channel
+const MaxPins = 50
+
+// Pin change of the channel broadcast to the server room; message only for the pin
+type PinsUpdate struct {
+	ServerID  snowflake.ID           `json:"serverID"`
+	ChannelID snowflake.ID           `json:"channelID"`
+	MessageID snowflake.ID           `json:"messageID"`
+	Pinned    bool                   `json:"pinned"`
+	UserID    snowflake.ID           `json:"userID"` // who pinned or unpinned
+	Message   *models.ChannelMessage `json:"message,omitempty"`
+	Changed   bool                   `json:"-"` // false if already in that state; nothing to broadcast
+}
+
+// Thread of the parent message with a page of its replies
+type Thread struct {
+	Parent  *models.ChannelMessage   `json:"parent"`
+	Replies []*models.ChannelMessage `json:"replies"`
+}
+
+// Server room of the channel message; every channel of the server is broadcast there
+func Room(serverID snowflake.ID) string {
+	return fmt.Sprintf("server:%d", serverID)
+}
+
+// Edit the message content; only the author can edit
+func EditMessage(ctx context.Context, userID, channelID, messageID snowflake.ID, content string) (*models.ChannelMessage, *appError.Error) {
+	message, appErr := channelMessageStore.GetChannelMessage(ctx, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if message.UserID != userID {
+		return nil, appError.NewForbidden("Only the author can edit the message")
+	}
+	if _, appErr := accessLib.ServerMember(ctx, userID, message.ServerID); appErr != nil {
+		return nil, appErr
+	}
+
+	// Mentions follow the edited content; added or removed by the edit. Unchecked mentions are dropped on failure, as on the create
+	mentions, appErr := mentionLib.Resolve(ctx, message.ServerID, userID, mentionLib.Parse(content))
+	if appErr != nil {
+		logrus.WithField("message_id", messageID).Warnf("Failed to resolve the mentions: %s", appErr.Message)
+	}
+	return channelMessageStore.UpdateChannelMessageContent(ctx, channelID, messageID, content, mentions)
+}
+
+// Soft delete the message; the author or the server admins and moderators can delete
+func DeleteMessage(ctx context.Context, userID, channelID, messageID snowflake.ID) (*DeletedMessage, *appError.Error) {
+	message, appErr := channelMessageStore.GetChannelMessage(ctx, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	member, appErr := accessLib.CurrentServerMember(ctx, userID, message.ServerID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	isModerator := member.Role == models.MemberRoleADMIN || member.Role == models.MemberRoleMODERATOR
+	if message.UserID != userID && !isModerator {
+		return nil, appError.NewForbidden("Only the author or a moderator can delete the message")
+	}
+
+	deleted, appErr := channelMessageStore.DeleteChannelMessage(ctx, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	// Deleted reply no longer counts in its thread
+	if deleted.ReferencedMessageID != nil {
+		channelMessageStore.RefreshThreadStats(ctx, []snowflake.ID{*deleted.ReferencedMessageID})
+	}
+
+	result := &DeletedMessage{
+		ID:                  deleted.ID,
+		ServerID:            deleted.ServerID,
+		ChannelID:           deleted.ChannelID,
+		DeletedBy:           userID,
+		ReferencedMessageID: deleted.ReferencedMessageID,
+	}
+	if deleted.PinnedAt != nil {
+		result.Unpinned = &PinsUpdate{
+			ServerID:  deleted.ServerID,
+			ChannelID: deleted.ChannelID,
+			MessageID: deleted.ID,
+			Pinned:    false,
+			UserID:    userID,
+			Changed:   true,
+		}
+	}
+	return result, nil
+}
+
+// Get the thread of the parent message; member of the server only
+func GetThread(ctx context.Context, userID, channelID, parentID snowflake.ID, limit int64, beforeID *snowflake.ID) (*Thread, *appError.Error) {
+	if _, _, appErr := accessLib.ReadableChannel(ctx, userID, channelID); appErr != nil {
+		return nil, appErr
+	}
+	parent, appErr := channelMessageStore.GetChannelMessage(ctx, channelID, parentID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	replies, appErr := channelMessageStore.GetChannelThreadMessages(ctx, parent, limit, beforeID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return &Thread{Parent: parent, Replies: replies}, nil
+}
+
+// Add the reaction of the server member on the message
+func AddReaction(ctx context.Context, userID, channelID, messageID snowflake.ID, emoji string) (*reactionLib.Result, *appError.Error) {
+	message, appErr := memberMessage(ctx, userID, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return reactionLib.Add(ctx, reactionStore.ChannelMessages, Room(message.ServerID), userID, messageID, emoji)
+}
+
+// Remove the reaction of the server member on the message
+func RemoveReaction(ctx context.Context, userID, channelID, messageID snowflake.ID, emoji string) (*reactionLib.Result, *appError.Error) {
+	message, appErr := memberMessage(ctx, userID, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return reactionLib.Remove(ctx, reactionStore.ChannelMessages, Room(message.ServerID), userID, messageID, emoji)
+}
+
+// Set the me flag of the message reactions for the user
+func MarkReactions(ctx context.Context, userID snowflake.ID, messages []*models.ChannelMessage) {
+	reactionsByMessage := make(map[snowflake.ID][]*models.Reaction, len(messages))
+	for _, message := range messages {
+		reactionsByMessage[message.ID] = message.Reactions
+	}
+	reactionLib.MarkMe(ctx, userID, reactionsByMessage)
+}
+
+// Pin or unpin the message; only the server admins and moderators can
+func SetPinned(ctx context.Context, userID, channelID, messageID snowflake.ID, pinned bool) (*PinsUpdate, *appError.Error) {
+	message, appErr := channelMessageStore.GetChannelMessage(ctx, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	member, appErr := accessLib.CurrentServerMember(ctx, userID, message.ServerID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if member.Role != models.MemberRoleADMIN && member.Role != models.MemberRoleMODERATOR {
+		return nil, appError.NewForbidden("Only the admins and moderators can pin messages")
+	}
+
+	message, changed, appErr := channelMessageStore.SetChannelMessagePinned(ctx, channelID, messageID, userID, pinned, MaxPins)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	update := &PinsUpdate{
+		ServerID:  message.ServerID,
+		ChannelID: channelID,
+		MessageID: messageID,
+		Pinned:    pinned,
+		UserID:    userID,
+		Changed:   changed,
+	}
+	if pinned {
+		update.Message = message
+	}
+	return update, nil
+}
+
+// Get the pinned messages of the channel; member of the server only
+func GetPins(ctx context.Context, userID, channelID snowflake.ID) ([]*models.ChannelMessage, *appError.Error) {
+	if _, _, appErr := accessLib.ReadableChannel(ctx, userID, channelID); appErr != nil {
+		return nil, appErr
+	}
+	return channelMessageStore.GetChannelPins(ctx, channelID, MaxPins)
+}
+
+// Message of the channel if the user is still a member of its server
+func memberMessage(ctx context.Context, userID, channelID, messageID snowflake.ID) (*models.ChannelMessage, *appError.Error) {
+	message, appErr := channelMessageStore.GetChannelMessage(ctx, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if _, appErr := accessLib.ServerMember(ctx, userID, message.ServerID); appErr != nil {
+		return nil, appErr
+	}
+	return message, nil
+}
@@ -0,0 +1,68 @@
+package connectionLib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Connection without heartbeat for this long is considered gone
+	ConnectionTTL = 90 * time.Second
+)
+
+// Acquire the connection slot of the user over every node; false if user has max live connections
+func Acquire(ctx context.Context, userID snowflake.ID, connectionID string, maxConnections int) (bool, *appError.Error) {
+	connectionsKey, boundedKey := rediskeys.Keys.Websocket.Connections(userID)
+
+	rawResult, err := redisDatabase.GlobalCacheManager.RunScript(
+		ctx,
+		boundedKey,
+		acquireConnectionScript,
+		[]string{connectionsKey},
+		connectionID,
+		maxConnections,
+		time.Now().UnixMilli(),
+		ConnectionTTL.Milliseconds(),
+		int(ConnectionTTL.Seconds()),
+	)
+	if err != nil {
+		return false, appError.NewInternal(err.Error())
+	}
+
+	acquired, ok := rawResult.(int64)
+	if !ok {
+		return false, appError.NewInternal(fmt.Sprintf("Unexpected script return type %T", rawResult))
+	}
+	return acquired == 1, nil
+}
+
+// Heartbeat the acquired connection so it is not taken as stale
+func Heartbeat(ctx context.Context, userID snowflake.ID, connectionID string) *appError.Error {
+	connectionsKey, _ := rediskeys.Keys.Websocket.Connections(userID)
+
+	pipe := redisDatabase.RedisClient.TxPipeline()
+	pipe.ZAddXX(ctx, connectionsKey, redis.Z{Score: float64(time.Now().UnixMilli()), Member: connectionID})
+	pipe.Expire(ctx, connectionsKey, ConnectionTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return appError.NewInternal(err.Error())
+	}
+	return nil
+}
+
+// Release the connection slot once disconnected
+func Release(ctx context.Context, userID snowflake.ID, connectionID string) *appError.Error {
+	connectionsKey, _ := rediskeys.Keys.Websocket.Connections(userID)
+
+	if err := redisDatabase.RedisClient.ZRem(ctx, connectionsKey, connectionID).Err(); err != nil {
+		return appError.NewInternal(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package connectionLib
+
+import "github.com/redis/go-redis/v9"
+
+// Acquire the user connection slot; live connections are scored by their last heartbeat
+var acquireConnectionScript = redis.NewScript(`
+	local connectionsKey = KEYS[1]
+	local connectionID = ARGV[1]
+	local maxConnections = tonumber(ARGV[2])
+	local nowMs = tonumber(ARGV[3])
+	local staleMs = tonumber(ARGV[4])
+	local ttlSeconds = tonumber(ARGV[5])
+
+	-- Connections of the crashed node; never heartbeat again
+	redis.call("ZREMRANGEBYSCORE", connectionsKey, "-inf", nowMs - staleMs)
+
+	if redis.call("ZSCORE", connectionsKey, connectionID) == false then
+		if redis.call("ZCARD", connectionsKey) >= maxConnections then
+			return 0 -- Limit reached
+		end
+	end
+
+	redis.call("ZADD", connectionsKey, nowMs, connectionID)
+	redis.call("EXPIRE", connectionsKey, ttlSeconds)
+	return 1
+`)
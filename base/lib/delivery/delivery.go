@@ -0,0 +1,100 @@
+package deliveryLib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+)
+
+// Topic of the message delivery status; consumed by every websocket hub
+const Topic = "broadcast.message.status"
+
+// Headers of the sent message; copied to the delivery status
+const (
+	SessionHeader = "session_id"
+	NonceHeader   = "nonce"
+)
+
+type Status string
+
+const (
+	StatusPersisted Status = "persisted"
+	StatusFailed    Status = "failed"
+)
+
+// Reason of the failed message
+type Reason string
+
+const (
+	ReasonInvalidMessage  Reason = "invalid_message"
+	ReasonPublishFailed   Reason = "publish_failed"
+	ReasonPersistFailed   Reason = "persist_failed"
+	ReasonParentNotFound  Reason = "parent_not_found"  // replied message is deleted or of other channel
+	ReasonChannelNotFound Reason = "channel_not_found" // channel is deleted or of other server
+)
+
+// Delivery status of the sent message for the sender sessions
+type MessageStatus struct {
+	ID        snowflake.ID `json:"id"`
+	Nonce     string       `json:"nonce,omitempty"`
+	Room      string       `json:"room"`
+	UserID    snowflake.ID `json:"userID"`
+	SessionID string       `json:"sessionID,omitempty"` // sender session; all user sessions if empty
+	Status    Status       `json:"status"`
+	Reason    Reason       `json:"reason,omitempty"`
+}
+
+// Delivery status of the consumed message; ids and sender from the headers, room from the key
+func StatusOfMessage(msg *kafka.Message, status Status, reason Reason) *MessageStatus {
+	metadata := baseKafka.ParseKafkaMessageHeaders(msg)
+	messageStatus := &MessageStatus{
+		ID:     metadata.TraceID,
+		Room:   string(msg.Key),
+		UserID: metadata.UserID,
+		Status: status,
+		Reason: reason,
+	}
+
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case SessionHeader:
+			messageStatus.SessionID = string(h.Value)
+		case NonceHeader:
+			messageStatus.Nonce = string(h.Value)
+		}
+	}
+	return messageStatus
+}
+
+// Publish the delivery statuses in a single write; keyed by user so the user statuses stay in order
+func Publish(ctx context.Context, producer *baseKafka.KafkaProducer, statuses []*MessageStatus) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, 0, len(statuses))
+	for _, status := range statuses {
+		// Sender unknown; nobody to tell
+		if status.UserID == 0 {
+			continue
+		}
+		data, err := json.Marshal(status)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, kafka.Message{
+			Topic: Topic,
+			Key:   []byte(fmt.Sprintf("user:%d", status.UserID)),
+			Value: data,
+		})
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return producer.WriteMessages(ctx, Topic, messages)
+}
@@ -0,0 +1,160 @@
+package directMessageLib
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	reactionLib "github.com/himanshu3889/discore-backend/base/lib/reaction"
+	"github.com/himanshu3889/discore-backend/base/models"
+	directMessageStore "github.com/himanshu3889/discore-backend/base/store/directMessage"
+	reactionStore "github.com/himanshu3889/discore-backend/base/store/reaction"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Deleted message broadcast to the conversation room
+type DeletedMessage struct {
+	ID             snowflake.ID `json:"id"`
+	ConversationID snowflake.ID `json:"conversationID"`
+	DeletedBy      snowflake.ID `json:"deletedBy"`
+	Unpinned       *PinsUpdate  `json:"-"` // pin change if the deleted message was pinned
+}
+
+// Max pinned messages of the conversation
+const MaxPins = 50
+
+// Pin change of the conversation broadcast to the direct room; message only for the pin
+type PinsUpdate struct {
+	ConversationID snowflake.ID          `json:"conversationID"`
+	MessageID      snowflake.ID          `json:"messageID"`
+	Pinned         bool                  `json:"pinned"`
+	UserID         snowflake.ID          `json:"userID"` // who pinned or unpinned
+	Message        *models.DirectMessage `json:"message,omitempty"`
+	Changed        bool                  `json:"-"` // false if already in that state; nothing to broadcast
+}
+
+// Direct room of the conversation; both participants subscribe it
+func Room(conversationID snowflake.ID) string {
+	return fmt.Sprintf("direct:%d", conversationID)
+}
+
+// Edit the message content; only the author can edit
+func EditMessage(ctx context.Context, userID, conversationID, messageID snowflake.ID, content string) (*models.DirectMessage, *appError.Error) {
+	if _, appErr := authorMessage(ctx, userID, conversationID, messageID); appErr != nil {
+		return nil, appErr
+	}
+	return directMessageStore.UpdateDirectMessageContent(ctx, conversationID, messageID, content)
+}
+
+// Soft delete the message; only the author can delete
+func DeleteMessage(ctx context.Context, userID, conversationID, messageID snowflake.ID) (*DeletedMessage, *appError.Error) {
+	if _, appErr := authorMessage(ctx, userID, conversationID, messageID); appErr != nil {
+		return nil, appErr
+	}
+
+	deleted, appErr := directMessageStore.DeleteDirectMessage(ctx, conversationID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	result := &DeletedMessage{
+		ID:             deleted.ID,
+		ConversationID: deleted.ConversationID,
+		DeletedBy:      userID,
+	}
+	if deleted.PinnedAt != nil {
+		result.Unpinned = &PinsUpdate{
+			ConversationID: deleted.ConversationID,
+			MessageID:      deleted.ID,
+			Pinned:         false,
+			UserID:         userID,
+			Changed:        true,
+		}
+	}
+	return result, nil
+}
+
+// Add the reaction of the participant on the message
+func AddReaction(ctx context.Context, userID, conversationID, messageID snowflake.ID, emoji string) (*reactionLib.Result, *appError.Error) {
+	if _, appErr := participantMessage(ctx, userID, conversationID, messageID); appErr != nil {
+		return nil, appErr
+	}
+	return reactionLib.Add(ctx, reactionStore.DirectMessages, Room(conversationID), userID, messageID, emoji)
+}
+
+// Remove the reaction of the participant on the message
+func RemoveReaction(ctx context.Context, userID, conversationID, messageID snowflake.ID, emoji string) (*reactionLib.Result, *appError.Error) {
+	if _, appErr := participantMessage(ctx, userID, conversationID, messageID); appErr != nil {
+		return nil, appErr
+	}
+	return reactionLib.Remove(ctx, reactionStore.DirectMessages, Room(conversationID), userID, messageID, emoji)
+}
+
+// Set the me flag of the message reactions for the user
+func MarkReactions(ctx context.Context, userID snowflake.ID, messages []*models.DirectMessage) {
+	reactionsByMessage := make(map[snowflake.ID][]*models.Reaction, len(messages))
+	for _, message := range messages {
+		reactionsByMessage[message.ID] = message.Reactions
+	}
+	reactionLib.MarkMe(ctx, userID, reactionsByMessage)
+}
+
+// Pin or unpin the message; either participant can
+func SetPinned(ctx context.Context, userID, conversationID, messageID snowflake.ID, pinned bool) (*PinsUpdate, *appError.Error) {
+	if _, appErr := participantMessage(ctx, userID, conversationID, messageID); appErr != nil {
+		return nil, appErr
+	}
+
+	message, changed, appErr := directMessageStore.SetDirectMessagePinned(ctx, conversationID, messageID, userID, pinned, MaxPins)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	update := &PinsUpdate{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		Pinned:         pinned,
+		UserID:         userID,
+		Changed:        changed,
+	}
+	if pinned {
+		update.Message = message
+	}
+	return update, nil
+}
+
+// Get the pinned messages of the conversation; participants only
+func GetPins(ctx context.Context, userID, conversationID snowflake.ID) ([]*models.DirectMessage, *appError.Error) {
+	participant, appErr := directMessageStore.HasValidConversationForUser(ctx, conversationID, userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !participant {
+		return nil, appError.NewNotFound("Conversation not found")
+	}
+	return directMessageStore.GetConversationPins(ctx, conversationID, MaxPins)
+}
+
+// Message of the conversation the user still participates
+func participantMessage(ctx context.Context, userID, conversationID, messageID snowflake.ID) (*models.DirectMessage, *appError.Error) {
+	participant, appErr := directMessageStore.HasValidConversationForUser(ctx, conversationID, userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !participant {
+		return nil, appError.NewNotFound("Conversation not found")
+	}
+	return directMessageStore.GetDirectMessage(ctx, conversationID, messageID)
+}
+
+// Message of the user in a conversation the user still participates
+func authorMessage(ctx context.Context, userID, conversationID, messageID snowflake.ID) (*models.DirectMessage, *appError.Error) {
+	message, appErr := participantMessage(ctx, userID, conversationID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if message.UserID != userID {
+		return nil, appError.NewForbidden("Only the author can change the message")
+	}
+	return message, nil
+}
@@ -0,0 +1,231 @@
+package mentionLib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	"github.com/himanshu3889/discore-backend/base/models"
+	serverStore "github.com/himanshu3889/discore-backend/base/store/server"
+	"github.com/himanshu3889/discore-backend/base/utils"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+)
+
+// Topic of the mention notifications; consumed by every websocket hub
+const Topic = "broadcast.notification.mention"
+
+const (
+	MaxUserMentions  = 50   // extra user mentions of the message are dropped
+	maxRoleRecipient = 1000 // role mention notifies at most these members
+)
+
+var (
+	userMentionPattern     = regexp.MustCompile(`<@(\d+)>`)
+	roleMentionPattern     = regexp.MustCompile(`(?i)(?:^|\s)@(admin|moderator)\b`)
+	everyoneMentionPattern = regexp.MustCompile(`(?i)(?:^|\s)@everyone\b`)
+)
+
+// Mention notification of the persisted message; server members if everyone, else the users
+type Notification struct {
+	MessageID snowflake.ID        `json:"messageID"`
+	ServerID  snowflake.ID        `json:"serverID"`
+	ChannelID snowflake.ID        `json:"channelID"`
+	AuthorID  snowflake.ID        `json:"authorID"`
+	UserIDs   []snowflake.ID      `json:"userIDs,omitempty"`
+	Roles     []models.MemberRole `json:"roles,omitempty"`
+	Everyone  bool                `json:"everyone,omitempty"`
+}
+
+// Parse the mentions of the content; GUEST is the default role so not mentionable. Nil if none
+func Parse(content string) *models.MessageMentions {
+	mentions := &models.MessageMentions{}
+
+	seenUsers := make(map[snowflake.ID]bool)
+	for _, match := range userMentionPattern.FindAllStringSubmatch(content, -1) {
+		userID, err := utils.ValidSnowflakeID(match[1])
+		if err != nil || seenUsers[userID] {
+			continue
+		}
+		seenUsers[userID] = true
+		mentions.UserIDs = append(mentions.UserIDs, userID)
+		if len(mentions.UserIDs) == MaxUserMentions {
+			break
+		}
+	}
+
+	seenRoles := make(map[models.MemberRole]bool)
+	for _, match := range roleMentionPattern.FindAllStringSubmatch(content, -1) {
+		role := models.MemberRole(strings.ToUpper(match[1]))
+		if !seenRoles[role] {
+			seenRoles[role] = true
+			mentions.Roles = append(mentions.Roles, role)
+		}
+	}
+
+	mentions.Everyone = everyoneMentionPattern.MatchString(content)
+
+	if len(mentions.UserIDs) == 0 && len(mentions.Roles) == 0 && !mentions.Everyone {
+		return nil
+	}
+	return mentions
+}
+
+// Check the mentions against the server membership; everyone only by the admins and moderators. Nil if none left
+func Resolve(ctx context.Context, serverID, authorID snowflake.ID, mentions *models.MessageMentions) (*models.MessageMentions, *appError.Error) {
+	if mentions == nil {
+		return nil, nil
+	}
+	roles, appErr := serverStore.GetServerMemberRoles(ctx, serverID, mentionedUserIDs([]*models.MessageMentions{mentions}, []snowflake.ID{authorID}))
+	if appErr != nil {
+		return nil, appErr
+	}
+	return resolveWith(roles, authorID, mentions), nil
+}
+
+// Resolve the mentions of the messages in place; one membership query per server. Unchecked mentions are dropped on failure
+func ResolveMessages(ctx context.Context, messages []*models.ChannelMessage) *appError.Error {
+	byServer := make(map[snowflake.ID][]*models.ChannelMessage)
+	for _, message := range messages {
+		if message.Mentions != nil {
+			byServer[message.ServerID] = append(byServer[message.ServerID], message)
+		}
+	}
+
+	var firstErr *appError.Error
+	for serverID, serverMessages := range byServer {
+		mentions := make([]*models.MessageMentions, len(serverMessages))
+		authorIDs := make([]snowflake.ID, len(serverMessages))
+		for i, message := range serverMessages {
+			mentions[i] = message.Mentions
+			authorIDs[i] = message.UserID
+		}
+
+		roles, appErr := serverStore.GetServerMemberRoles(ctx, serverID, mentionedUserIDs(mentions, authorIDs))
+		for _, message := range serverMessages {
+			if appErr != nil {
+				message.Mentions = nil
+				continue
+			}
+			message.Mentions = resolveWith(roles, message.UserID, message.Mentions)
+		}
+		if appErr != nil && firstErr == nil {
+			firstErr = appErr
+		}
+	}
+	return firstErr
+}
+
+// Users whose membership the mentions need; mentioned users and the authors mentioning everyone
+func mentionedUserIDs(mentions []*models.MessageMentions, authorIDs []snowflake.ID) []snowflake.ID {
+	seen := make(map[snowflake.ID]bool)
+	userIDs := []snowflake.ID{}
+	add := func(userID snowflake.ID) {
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	for i, mention := range mentions {
+		for _, userID := range mention.UserIDs {
+			add(userID)
+		}
+		if mention.Everyone {
+			add(authorIDs[i])
+		}
+	}
+	return userIDs
+}
+
+// Mentions left after the membership check of the member roles
+func resolveWith(roles map[snowflake.ID]models.MemberRole, authorID snowflake.ID, mentions *models.MessageMentions) *models.MessageMentions {
+	resolved := &models.MessageMentions{Roles: mentions.Roles}
+
+	if mentions.Everyone {
+		role, isMember := roles[authorID]
+		resolved.Everyone = isMember && (role == models.MemberRoleADMIN || role == models.MemberRoleMODERATOR)
+	}
+
+	// Keep the mention order of the content
+	for _, userID := range mentions.UserIDs {
+		if _, isMember := roles[userID]; isMember {
+			resolved.UserIDs = append(resolved.UserIDs, userID)
+		}
+	}
+
+	if len(resolved.UserIDs) == 0 && len(resolved.Roles) == 0 && !resolved.Everyone {
+		return nil
+	}
+	return resolved
+}
+
+// Notification of the message mentions; role mentions resolved to the members, author never notified. Nil if nobody to notify
+func NotificationOf(ctx context.Context, message *models.ChannelMessage) (*Notification, *appError.Error) {
+	mentions := message.Mentions
+	if mentions == nil {
+		return nil, nil
+	}
+
+	notification := &Notification{
+		MessageID: message.ID,
+		ServerID:  message.ServerID,
+		ChannelID: message.ChannelID,
+		AuthorID:  message.UserID,
+		Roles:     mentions.Roles,
+		Everyone:  mentions.Everyone,
+	}
+	if mentions.Everyone {
+		return notification, nil
+	}
+
+	recipientIDs := mentions.UserIDs
+	if len(mentions.Roles) > 0 {
+		roleMemberIDs, appErr := serverStore.GetServerMemberUserIDsByRoles(ctx, message.ServerID, mentions.Roles, maxRoleRecipient)
+		if appErr != nil {
+			return nil, appErr
+		}
+		recipientIDs = append(append([]snowflake.ID{}, recipientIDs...), roleMemberIDs...)
+	}
+
+	seen := make(map[snowflake.ID]bool, len(recipientIDs))
+	for _, userID := range recipientIDs {
+		if userID == message.UserID || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		notification.UserIDs = append(notification.UserIDs, userID)
+	}
+	if len(notification.UserIDs) == 0 {
+		return nil, nil
+	}
+	return notification, nil
+}
+
+// Publish the mention notifications in a single write; keyed by server
+func Publish(ctx context.Context, producer *baseKafka.KafkaProducer, notifications []*Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	messages := make([]kafka.Message, 0, len(notifications))
+	for _, notification := range notifications {
+		data, err := json.Marshal(notification)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, kafka.Message{
+			Topic: Topic,
+			Key:   []byte(fmt.Sprintf("server:%d", notification.ServerID)),
+			Value: data,
+		})
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return producer.WriteMessages(ctx, Topic, messages)
+}
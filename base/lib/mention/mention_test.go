@@ -0,0 +1,93 @@
+package mentionLib
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+func TestParse(t *testing.T) {
+	var manyUsers strings.Builder
+	var firstUsers []snowflake.ID
+	for i := 1; i <= MaxUserMentions+5; i++ {
+		fmt.Fprintf(&manyUsers, "<@%d> ", i)
+		if i <= MaxUserMentions {
+			firstUsers = append(firstUsers, snowflake.ID(i))
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    *models.MessageMentions
+	}{
+		{
+			name:    "no mentions",
+			content: "hello there",
+			want:    nil,
+		},
+		{
+			name:    "users in the content order without duplicates",
+			content: "<@22> hi <@11> and <@22> again",
+			want:    &models.MessageMentions{UserIDs: []snowflake.ID{22, 11}},
+		},
+		{
+			name:    "invalid user id dropped",
+			content: "<@99999999999999999999999> <@0>",
+			want:    nil,
+		},
+		{
+			name:    "user mentions capped",
+			content: manyUsers.String(),
+			want:    &models.MessageMentions{UserIDs: firstUsers},
+		},
+		{
+			name:    "roles case insensitive without duplicates",
+			content: "@Moderator please ask @admin and @MODERATOR",
+			want:    &models.MessageMentions{Roles: []models.MemberRole{models.MemberRoleMODERATOR, models.MemberRoleADMIN}},
+		},
+		{
+			name:    "guest role not mentionable",
+			content: "@guest hi",
+			want:    nil,
+		},
+		{
+			name:    "role inside a word ignored",
+			content: "mail me at team@admin or @administrators",
+			want:    nil,
+		},
+		{
+			name:    "everyone",
+			content: "hey @everyone",
+			want:    &models.MessageMentions{Everyone: true},
+		},
+		{
+			name:    "everyone inside a word ignored",
+			content: "me@everyone @everyones",
+			want:    nil,
+		},
+		{
+			name:    "all kinds",
+			content: "@everyone <@7> @admin",
+			want: &models.MessageMentions{
+				UserIDs:  []snowflake.ID{7},
+				Roles:    []models.MemberRole{models.MemberRoleADMIN},
+				Everyone: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.content)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package modelsLib
+
+import "github.com/himanshu3889/discore-backend/base/models"
+
+// Clear the server owned fields of the client message; reply stats by the thread refresh, mentions by the
+// chat pipeline, reactions and pins by their own events
+func SanitizeIncomingChannelMessage(message *models.ChannelMessage) {
+	message.ReplyCount = 0
+	message.LastReplyAt = nil
+	message.Mentions = nil
+	message.Reactions = nil
+	message.PinnedAt = nil
+	message.PinnedBy = nil
+}
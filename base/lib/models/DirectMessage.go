@@ -0,0 +1,10 @@
+package modelsLib
+
+import "github.com/himanshu3889/discore-backend/base/models"
+
+// Clear the server owned fields of the client message; reactions and pins by their own events
+func SanitizeIncomingDirectMessage(message *models.DirectMessage) {
+	message.Reactions = nil
+	message.PinnedAt = nil
+	message.PinnedBy = nil
+}
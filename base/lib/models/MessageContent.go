@@ -0,0 +1,21 @@
+package modelsLib
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+)
+
+// Max characters of the message content; same for the create and the edit
+const MaxMessageContentLength = 2000
+
+// Trimmed content of the message; bad request if longer than the max characters
+func ValidMessageContent(content string) (string, *appError.Error) {
+	content = strings.TrimSpace(content)
+	if utf8.RuneCountInString(content) > MaxMessageContentLength {
+		return "", appError.NewBadRequest(fmt.Sprintf("message content can have at most %d characters", MaxMessageContentLength))
+	}
+	return content, nil
+}
@@ -0,0 +1,36 @@
+package modelsLib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidMessageContent(t *testing.T) {
+	maxContent := strings.Repeat("é", MaxMessageContentLength) // multi byte; the max counts characters
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		valid   bool
+	}{
+		{name: "plain", content: "hello", want: "hello", valid: true},
+		{name: "trimmed", content: "  hello \n\t", want: "hello", valid: true},
+		{name: "blank left to the caller", content: " \n ", want: "", valid: true},
+		{name: "max characters", content: maxContent, want: maxContent, valid: true},
+		{name: "max characters after the trim", content: "  " + maxContent + "  ", want: maxContent, valid: true},
+		{name: "over the max characters", content: maxContent + "a", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, appErr := ValidMessageContent(tt.content)
+			if (appErr == nil) != tt.valid {
+				t.Fatalf("ValidMessageContent() error = %v, want valid %v", appErr, tt.valid)
+			}
+			if got != tt.want {
+				t.Errorf("ValidMessageContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package paginationLib
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+// Find the page of the snowflake keyed documents; one extra document is fetched per paged side to know if more exists,
+// the other side of the cursor is checked by an existence query
+func Find[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, query *models.PageQuery, idOf func(T) snowflake.ID) ([]T, *models.PageInfo, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	var older, newer []T
+	var hasOlder, hasNewer bool
+	var err error
+
+	switch {
+	case query.Direction == models.PageAfter && query.Cursor != nil:
+		if newer, hasNewer, err = findSide[T](ctx, collection, filter, bson.M{"$gt": *query.Cursor}, 1, limit); err != nil {
+			return nil, nil, err
+		}
+		hasOlder, err = existsSide(ctx, collection, filter, bson.M{"$lte": *query.Cursor})
+
+	case query.Direction == models.PageAround && query.Cursor != nil:
+		// Cursor message is in the older half
+		olderLimit := limit - limit/2
+		if older, hasOlder, err = findSide[T](ctx, collection, filter, bson.M{"$lte": *query.Cursor}, -1, olderLimit); err != nil {
+			return nil, nil, err
+		}
+		newer, hasNewer, err = findSide[T](ctx, collection, filter, bson.M{"$gt": *query.Cursor}, 1, limit/2)
+
+	default:
+		var idFilter bson.M
+		if query.Cursor != nil {
+			idFilter = bson.M{"$lt": *query.Cursor}
+			if hasNewer, err = existsSide(ctx, collection, filter, bson.M{"$gte": *query.Cursor}); err != nil {
+				return nil, nil, err
+			}
+		}
+		older, hasOlder, err = findSide[T](ctx, collection, filter, idFilter, -1, limit)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Latest first
+	slices.Reverse(newer)
+	docs := append(newer, older...)
+
+	info := &models.PageInfo{HasOlder: hasOlder, HasNewer: hasNewer}
+	switch query.Direction {
+	case models.PageAfter:
+		info.HasMore = hasNewer
+	case models.PageAround:
+		info.HasMore = hasOlder || hasNewer
+	default:
+		info.HasMore = hasOlder
+	}
+	if len(docs) > 0 {
+		if hasOlder {
+			prevCursor := idOf(docs[len(docs)-1])
+			info.PrevCursor = &prevCursor
+		}
+		if hasNewer {
+			nextCursor := idOf(docs[0])
+			info.NextCursor = &nextCursor
+		}
+	}
+	return docs, info, nil
+}
+
+// Find the documents of one side of the cursor in the sort order; true if more exists past the limit
+func findSide[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, idFilter bson.M, sort int, limit int64) ([]T, bool, error) {
+	opts := options.Find().SetSort(bson.D{{"_id", sort}}).SetLimit(limit + 1)
+	cursor, err := collection.Find(ctx, sideFilter(filter, idFilter), opts)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []T{}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, false, err
+	}
+	if int64(len(docs)) > limit {
+		return docs[:limit], true, nil
+	}
+	return docs, false, nil
+}
+
+// Does any document exist on the side of the cursor not fetched by the page
+func existsSide(ctx context.Context, collection *mongo.Collection, filter bson.M, idFilter bson.M) (bool, error) {
+	opts := options.FindOne().SetProjection(bson.M{"_id": 1})
+	err := collection.FindOne(ctx, sideFilter(filter, idFilter), opts).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Filter of the documents with the id filter of the side
+func sideFilter(filter bson.M, idFilter bson.M) bson.M {
+	side := make(bson.M, len(filter)+1)
+	for key, value := range filter {
+		side[key] = value
+	}
+	if idFilter != nil {
+		side["_id"] = idFilter
+	}
+	return side
+}
@@ -0,0 +1,128 @@
+package paginationLib
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type pageDoc struct {
+	ID snowflake.ID `bson:"_id"`
+}
+
+// Cursor response of one find; the mock answers the queries in the order they are sent
+func findResponse(ns string, ids ...snowflake.ID) bson.D {
+	docs := make([]bson.D, len(ids))
+	for i, id := range ids {
+		docs[i] = bson.D{{"_id", id}}
+	}
+	return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, docs...)
+}
+
+func idPtr(id snowflake.ID) *snowflake.ID {
+	return &id
+}
+
+func TestFind(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name      string
+		query     models.PageQuery
+		responses [][]snowflake.ID // documents of each query in the send order
+		wantIDs   []snowflake.ID
+		wantInfo  models.PageInfo
+	}{
+		{
+			name:      "latest page with older",
+			query:     models.PageQuery{Limit: 2},
+			responses: [][]snowflake.ID{{5, 4, 3}},
+			wantIDs:   []snowflake.ID{5, 4},
+			wantInfo:  models.PageInfo{HasMore: true, HasOlder: true, PrevCursor: idPtr(4)},
+		},
+		{
+			name:      "latest page is everything",
+			query:     models.PageQuery{Limit: 5},
+			responses: [][]snowflake.ID{{2, 1}},
+			wantIDs:   []snowflake.ID{2, 1},
+			wantInfo:  models.PageInfo{},
+		},
+		{
+			name:      "before the cursor; newer side checked first",
+			query:     models.PageQuery{Direction: models.PageBefore, Cursor: idPtr(10), Limit: 2},
+			responses: [][]snowflake.ID{{10}, {9, 8}},
+			wantIDs:   []snowflake.ID{9, 8},
+			wantInfo:  models.PageInfo{HasNewer: true, NextCursor: idPtr(9)},
+		},
+		{
+			name:      "after the cursor; latest first",
+			query:     models.PageQuery{Direction: models.PageAfter, Cursor: idPtr(5), Limit: 2},
+			responses: [][]snowflake.ID{{6, 7, 8}, {5}},
+			wantIDs:   []snowflake.ID{7, 6},
+			wantInfo:  models.PageInfo{HasMore: true, HasOlder: true, HasNewer: true, PrevCursor: idPtr(6), NextCursor: idPtr(7)},
+		},
+		{
+			name:      "after the latest message",
+			query:     models.PageQuery{Direction: models.PageAfter, Cursor: idPtr(5), Limit: 2},
+			responses: [][]snowflake.ID{{}, {}},
+			wantIDs:   []snowflake.ID{},
+			wantInfo:  models.PageInfo{},
+		},
+		{
+			name:      "around the cursor; cursor in the older half",
+			query:     models.PageQuery{Direction: models.PageAround, Cursor: idPtr(5), Limit: 3},
+			responses: [][]snowflake.ID{{5, 4, 3}, {6}},
+			wantIDs:   []snowflake.ID{6, 5, 4},
+			wantInfo:  models.PageInfo{HasMore: true, HasOlder: true, PrevCursor: idPtr(4)},
+		},
+		{
+			name:      "around without the cursor is the latest page",
+			query:     models.PageQuery{Direction: models.PageAround, Limit: 2},
+			responses: [][]snowflake.ID{{3, 2}},
+			wantIDs:   []snowflake.ID{3, 2},
+			wantInfo:  models.PageInfo{},
+		},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			ns := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+			for _, ids := range tt.responses {
+				mt.AddMockResponses(findResponse(ns, ids...))
+			}
+
+			docs, info, err := Find(context.Background(), mt.Coll, bson.M{}, &tt.query, func(doc pageDoc) snowflake.ID { return doc.ID })
+			if err != nil {
+				mt.Fatalf("Find() error = %v", err)
+			}
+			ids := make([]snowflake.ID, len(docs))
+			for i, doc := range docs {
+				ids[i] = doc.ID
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				mt.Errorf("Find() ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(*info, tt.wantInfo) {
+				mt.Errorf("Find() info = %+v, want %+v", pageInfoString(info), pageInfoString(&tt.wantInfo))
+			}
+		})
+	}
+}
+
+// Page info with the cursors dereferenced for the failure message
+func pageInfoString(info *models.PageInfo) map[string]interface{} {
+	out := map[string]interface{}{"hasMore": info.HasMore, "hasOlder": info.HasOlder, "hasNewer": info.HasNewer}
+	if info.PrevCursor != nil {
+		out["prevCursor"] = *info.PrevCursor
+	}
+	if info.NextCursor != nil {
+		out["nextCursor"] = *info.NextCursor
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package presenceLib
+
+import "github.com/redis/go-redis/v9"
+
+// Update the session presence and aggregate the user status over all the sessions (devices)
+var updatePresenceScript = redis.NewScript(`
+	local sessionsKey = KEYS[1]
+	local statusKey = KEYS[2]
+	local sessionID = ARGV[1]
+	local sessionStatus = ARGV[2] -- empty to remove the session
+	local nowMs = tonumber(ARGV[3])
+	local staleMs = tonumber(ARGV[4])
+	local ttlSeconds = tonumber(ARGV[5])
+
+	if sessionStatus == "" then
+		redis.call("HDEL", sessionsKey, sessionID)
+	else
+		redis.call("HSET", sessionsKey, sessionID, sessionStatus .. ":" .. nowMs)
+		redis.call("EXPIRE", sessionsKey, ttlSeconds)
+	end
+
+	-- Any online session makes user online; only idle sessions makes user idle
+	local aggregate = "offline"
+	local entries = redis.call("HGETALL", sessionsKey)
+	for i = 1, #entries, 2 do
+		local value = entries[i + 1]
+		local sep = string.find(value, ":")
+		local status = string.sub(value, 1, sep - 1)
+		local seenAt = tonumber(string.sub(value, sep + 1))
+
+		if nowMs - seenAt > staleMs then
+			-- Session of the crashed node; never heartbeat again
+			redis.call("HDEL", sessionsKey, entries[i])
+		elseif status == "online" then
+			aggregate = "online"
+		elseif aggregate == "offline" then
+			aggregate = "idle"
+		end
+	end
+
+	local previous = redis.call("GET", statusKey) or "offline"
+	if aggregate == "offline" then
+		redis.call("DEL", statusKey)
+	else
+		redis.call("SET", statusKey, aggregate, "EX", ttlSeconds)
+	end
+
+	return {aggregate, previous}
+`)
@@ -0,0 +1,104 @@
+package presenceLib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+type Status string
+
+const (
+	StatusOnline  Status = "online"
+	StatusIdle    Status = "idle"
+	StatusOffline Status = "offline"
+)
+
+const (
+	// Session without heartbeat for this long is considered gone
+	PresenceTTL = 90 * time.Second
+)
+
+// Presence change of the user aggregated over all the sessions
+type Change struct {
+	Status   Status
+	Previous Status
+}
+
+// Has user status changed
+func (c *Change) Changed() bool {
+	return c.Status != c.Previous
+}
+
+// Heartbeat the user session with its status; returns the aggregated user status
+func Heartbeat(ctx context.Context, userID snowflake.ID, sessionID string, status Status) (*Change, *appError.Error) {
+	return updatePresence(ctx, userID, sessionID, status)
+}
+
+// Remove the user session; returns the aggregated user status
+func RemoveSession(ctx context.Context, userID snowflake.ID, sessionID string) (*Change, *appError.Error) {
+	return updatePresence(ctx, userID, sessionID, "")
+}
+
+func updatePresence(ctx context.Context, userID snowflake.ID, sessionID string, status Status) (*Change, *appError.Error) {
+	sessionsKey, boundedKey := rediskeys.Keys.Presence.Sessions(userID)
+	statusKey, _ := rediskeys.Keys.Presence.Status(userID)
+
+	rawResult, err := redisDatabase.GlobalCacheManager.RunScript(
+		ctx,
+		boundedKey,
+		updatePresenceScript,
+		[]string{sessionsKey, statusKey},
+		sessionID,
+		string(status),
+		time.Now().UnixMilli(),
+		PresenceTTL.Milliseconds(),
+		int(PresenceTTL.Seconds()),
+	)
+	if err != nil {
+		return nil, appError.NewInternal(err.Error())
+	}
+
+	result, ok := rawResult.([]interface{})
+	if !ok || len(result) != 2 {
+		return nil, appError.NewInternal("Unexpected script return type")
+	}
+
+	return &Change{
+		Status:   Status(fmt.Sprint(result[0])),
+		Previous: Status(fmt.Sprint(result[1])),
+	}, nil
+}
+
+// Get the users aggregated status; missing users are offline
+func GetUsersStatus(ctx context.Context, userIDs []snowflake.ID) (map[snowflake.ID]Status, *appError.Error) {
+	statuses := make(map[snowflake.ID]Status, len(userIDs))
+	if len(userIDs) == 0 {
+		return statuses, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	var boundedKey string
+	for i, id := range userIDs {
+		keys[i], boundedKey = rediskeys.Keys.Presence.Status(id)
+		statuses[id] = StatusOffline
+	}
+
+	cached, err := redisDatabase.GlobalCacheManager.MGet(ctx, boundedKey, keys)
+	if err != nil {
+		return statuses, appError.NewInternal(err.Error())
+	}
+
+	for i, key := range keys {
+		if raw := cached[key]; raw != nil {
+			statuses[userIDs[i]] = Status(raw)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,129 @@
+package reactionLib
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	broadcastLib "github.com/himanshu3889/discore-backend/base/lib/broadcast"
+	"github.com/himanshu3889/discore-backend/base/models"
+	reactionStore "github.com/himanshu3889/discore-backend/base/store/reaction"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+)
+
+// Event of the reaction changes; every hub coalesces them per room
+const Event = "message.reaction"
+
+const (
+	MaxEmojis   = 20 // distinct emojis per message
+	maxEmojiLen = 64 // unicode sequence or the custom emoji name
+)
+
+// Reaction change of the message; broadcast to the room of the message
+type Change struct {
+	MessageID snowflake.ID `json:"messageID"`
+	Emoji     string       `json:"emoji"`
+	UserID    snowflake.ID `json:"userID"`
+	Added     bool         `json:"added"`
+	Count     int64        `json:"count"` // emoji count after the change
+}
+
+// Reactions of the message after the add or remove; change is nil if nothing changed
+type Result struct {
+	Room      string             `json:"-"`
+	Reactions []*models.Reaction `json:"reactions"`
+	Change    *Change            `json:"-"`
+}
+
+// Validate the emoji of the reaction
+func ValidEmoji(emoji string) *appError.Error {
+	if emoji == "" || len(emoji) > maxEmojiLen || strings.IndexFunc(emoji, unicode.IsSpace) >= 0 {
+		return appError.NewBadRequest("Invalid emoji")
+	}
+	return nil
+}
+
+// Add the user reaction on the message of the room; no change if the user already reacted with the emoji
+func Add(ctx context.Context, collection string, room string, userID, messageID snowflake.ID, emoji string) (*Result, *appError.Error) {
+	if appErr := ValidEmoji(emoji); appErr != nil {
+		return nil, appErr
+	}
+	reactions, added, appErr := reactionStore.AddReaction(ctx, collection, messageID, userID, emoji, MaxEmojis)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return resultOf(ctx, room, userID, messageID, emoji, true, added, reactions), nil
+}
+
+// Remove the user reaction on the message of the room; no change if the user never reacted with the emoji
+func Remove(ctx context.Context, collection string, room string, userID, messageID snowflake.ID, emoji string) (*Result, *appError.Error) {
+	if appErr := ValidEmoji(emoji); appErr != nil {
+		return nil, appErr
+	}
+	reactions, removed, appErr := reactionStore.RemoveReaction(ctx, collection, messageID, userID, emoji)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return resultOf(ctx, room, userID, messageID, emoji, false, removed, reactions), nil
+}
+
+// Result with the user me flags and the change if applied
+func resultOf(ctx context.Context, room string, userID, messageID snowflake.ID, emoji string, added bool, applied bool, reactions []*models.Reaction) *Result {
+	MarkMe(ctx, userID, map[snowflake.ID][]*models.Reaction{messageID: reactions})
+
+	result := &Result{Room: room, Reactions: reactions}
+	if !applied {
+		return result
+	}
+
+	result.Change = &Change{MessageID: messageID, Emoji: emoji, UserID: userID, Added: added}
+	for _, reaction := range reactions {
+		if reaction.Emoji == emoji {
+			result.Change.Count = reaction.Count
+		}
+	}
+	return result
+}
+
+// Set the me flag of the message reactions for the user; left unset if the user reactions fetch fails
+func MarkMe(ctx context.Context, userID snowflake.ID, reactionsByMessage map[snowflake.ID][]*models.Reaction) {
+	messageIDs := make([]snowflake.ID, 0, len(reactionsByMessage))
+	for messageID, reactions := range reactionsByMessage {
+		if len(reactions) > 0 {
+			messageIDs = append(messageIDs, messageID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return
+	}
+
+	userReactions, appErr := reactionStore.GetUserReactions(ctx, userID, messageIDs)
+	if appErr != nil {
+		return
+	}
+	for messageID, reactions := range reactionsByMessage {
+		for _, reaction := range reactions {
+			reaction.Me = userReactions[messageID][reaction.Emoji]
+		}
+	}
+}
+
+// Publish the reaction change to the room; change is already stored so only logged on failure
+func Publish(ctx context.Context, producer *baseKafka.KafkaProducer, result *Result) {
+	if result.Change == nil {
+		return
+	}
+	data, err := json.Marshal(result.Change)
+	if err != nil {
+		return
+	}
+	// Keyed by room; changes of the room stay in order
+	if err := producer.Send(ctx, broadcastLib.Topic(Event), result.Room, data, result.Change.UserID); err != nil {
+		logrus.WithError(err).Error("Failed to forward the reaction to broadcast topic")
+	}
+}
@@ -0,0 +1,33 @@
+package reactionLib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		valid bool
+	}{
+		{name: "unicode emoji", emoji: "👍", valid: true},
+		{name: "joined unicode sequence", emoji: "👩‍👩‍👧", valid: true},
+		{name: "custom emoji name", emoji: "party_parrot", valid: true},
+		{name: "longest allowed", emoji: strings.Repeat("a", maxEmojiLen), valid: true},
+		{name: "empty", emoji: "", valid: false},
+		{name: "too long", emoji: strings.Repeat("a", maxEmojiLen+1), valid: false},
+		{name: "space", emoji: "thumbs up", valid: false},
+		{name: "unicode space", emoji: "👍 ", valid: false},
+		{name: "newline", emoji: "👍\n", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := ValidEmoji(tt.emoji)
+			if (appErr == nil) != tt.valid {
+				t.Errorf("ValidEmoji(%q) = %v, want valid %v", tt.emoji, appErr, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package readStateLib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	accessLib "github.com/himanshu3889/discore-backend/base/lib/access"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	"github.com/himanshu3889/discore-backend/base/models"
+	channelMessageStore "github.com/himanshu3889/discore-backend/base/store/channelMessage"
+	directMessageStore "github.com/himanshu3889/discore-backend/base/store/directMessage"
+	readStateStore "github.com/himanshu3889/discore-backend/base/store/readState"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Topic of the read state changes; consumed by every websocket hub to sync the user devices
+const Topic = "broadcast.read_state"
+
+const (
+	MaxUnreadCount = 100 // counts stop here; shown as 100+
+	maxCountWorker = 8
+	maxClockSkew   = time.Minute // ack of the message id further in the future is rejected
+)
+
+// Read state change of the user; channel or conversation
+type Update struct {
+	UserID         snowflake.ID `json:"userID"`
+	ChannelID      snowflake.ID `json:"channelID,omitempty"`
+	ConversationID snowflake.ID `json:"conversationID,omitempty"`
+	LastReadID     snowflake.ID `json:"lastReadID"`
+	Changed        bool         `json:"-"` // false if already read past it; current state, nothing to publish
+}
+
+// Mark the channel read up to the message; current read state if already read past it
+func AckChannel(ctx context.Context, userID, channelID, messageID snowflake.ID) (*Update, *appError.Error) {
+	if appErr := validAck(messageID); appErr != nil {
+		return nil, appErr
+	}
+	if _, _, appErr := accessLib.ReadableChannel(ctx, userID, channelID); appErr != nil {
+		return nil, appErr
+	}
+
+	update, appErr := ack(ctx, userID, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	update.ChannelID = channelID
+	return update, nil
+}
+
+// Mark the conversation read up to the message; current read state if already read past it
+func AckConversation(ctx context.Context, userID, conversationID, messageID snowflake.ID) (*Update, *appError.Error) {
+	if appErr := validAck(messageID); appErr != nil {
+		return nil, appErr
+	}
+	participant, appErr := directMessageStore.HasValidConversationForUser(ctx, conversationID, userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !participant {
+		return nil, appError.NewNotFound("Conversation not found")
+	}
+
+	update, appErr := ack(ctx, userID, conversationID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	update.ConversationID = conversationID
+	return update, nil
+}
+
+// Read states of the server channels with the unread and mention counts of the member
+func ChannelReadStates(ctx context.Context, userID snowflake.ID, role models.MemberRole, channelIDs []snowflake.ID) ([]*models.ReadState, *appError.Error) {
+	return readStates(ctx, userID, channelIDs, func(ctx context.Context, state *models.ReadState) *appError.Error {
+		unread, appErr := channelMessageStore.CountChannelUnread(ctx, state.ID, userID, state.LastReadID, MaxUnreadCount)
+		if appErr != nil || unread == 0 {
+			return appErr
+		}
+		state.UnreadCount = unread
+
+		state.MentionCount, appErr = channelMessageStore.CountChannelMentions(ctx, state.ID, userID, role, state.LastReadID, MaxUnreadCount)
+		return appErr
+	})
+}
+
+// Read states of the conversations with the unread counts; every direct message mentions the user
+func ConversationReadStates(ctx context.Context, userID snowflake.ID, conversationIDs []snowflake.ID) ([]*models.ReadState, *appError.Error) {
+	return readStates(ctx, userID, conversationIDs, func(ctx context.Context, state *models.ReadState) *appError.Error {
+		unread, appErr := directMessageStore.CountConversationUnread(ctx, state.ID, userID, state.LastReadID, MaxUnreadCount)
+		if appErr != nil {
+			return appErr
+		}
+		state.UnreadCount = unread
+		state.MentionCount = unread
+		return nil
+	})
+}
+
+// Publish the read state change to the hubs; keyed by the user so the changes stay in order
+func Publish(ctx context.Context, producer *baseKafka.KafkaProducer, update *Update) error {
+	if update == nil || !update.Changed {
+		return nil
+	}
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return producer.Send(ctx, Topic, fmt.Sprintf("user:%d", update.UserID), data, update.UserID)
+}
+
+// Move the read state of the target forward; the stored state if it is already read past the message
+func ack(ctx context.Context, userID, targetID, messageID snowflake.ID) (*Update, *appError.Error) {
+	advanced, appErr := readStateStore.Ack(ctx, userID, targetID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if advanced {
+		return &Update{UserID: userID, LastReadID: messageID, Changed: true}, nil
+	}
+
+	stored, appErr := readStateStore.GetReadStates(ctx, userID, []snowflake.ID{targetID})
+	if appErr != nil {
+		return nil, appErr
+	}
+	update := &Update{UserID: userID, LastReadID: messageID}
+	if state := stored[targetID]; state != nil {
+		update.LastReadID = state.LastReadID
+	}
+	return update, nil
+}
+
+// Read states of the targets in order, counted concurrently; never read target counts from the start.
+// Failed count is logged and left zero so one target never fails the list
+func readStates(ctx context.Context, userID snowflake.ID, targetIDs []snowflake.ID, count func(context.Context, *models.ReadState) *appError.Error) ([]*models.ReadState, *appError.Error) {
+	stored, appErr := readStateStore.GetReadStates(ctx, userID, targetIDs)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	states := make([]*models.ReadState, len(targetIDs))
+	var group errgroup.Group
+	group.SetLimit(maxCountWorker)
+	for i, targetID := range targetIDs {
+		state := stored[targetID]
+		if state == nil {
+			state = &models.ReadState{ID: targetID, UserID: userID}
+		}
+		states[i] = state
+
+		group.Go(func() error {
+			if appErr := count(ctx, state); appErr != nil {
+				logrus.WithFields(logrus.Fields{
+					"user_id":   userID,
+					"target_id": state.ID,
+				}).Warnf("Unread counts left zero: %s", appErr.Message)
+				state.UnreadCount = 0
+				state.MentionCount = 0
+			}
+			return nil
+		})
+	}
+	group.Wait()
+	return states, nil
+}
+
+// Message id of the ack must be a valid snowflake not from the future
+func validAck(messageID snowflake.ID) *appError.Error {
+	if messageID <= 0 || time.UnixMilli(messageID.Time()).After(time.Now().Add(maxClockSkew)) {
+		return appError.NewBadRequest("Invalid message id")
+	}
+	return nil
+}
@@ -13,6 +13,10 @@ func (k userKeys) Info(id snowflake.ID) (string, string) {
 	return fmt.Sprintf("discore:user:%d:info", id), "user:id:info" // cacheKey, "entity:operation"
 }
 
+func (k userKeys) Session(sessionID snowflake.ID) (string, string) {
+	return fmt.Sprintf("discore:user:session:%d:active", sessionID), "user:session_id:active"
+}
+
 // Server
 type serverKeys struct{}
 
@@ -27,6 +31,17 @@ func (k channelKeys) Info(id snowflake.ID) (string, string) {
 	return fmt.Sprintf("discore:channel:%d:info", id), "channel:id:info"
 }
 
+// Member
+type memberKeys struct{}
+
+func (k memberKeys) Info(serverID snowflake.ID, userID snowflake.ID) (string, string) {
+	return fmt.Sprintf("discore:member:%d:%d:info", serverID, userID), "member:server_id:user_id:info"
+}
+
+func (k memberKeys) Tombstone(serverID snowflake.ID, userID snowflake.ID) (string, string) {
+	return fmt.Sprintf("discore:member:%d:%d:tombstone", serverID, userID), "member:server_id:user_id:tombstone"
+}
+
 // Server Invite
 type serverInviteKeys struct{}
 
@@ -42,10 +57,43 @@ func (k serverInviteKeys) UseCountLuaScript() string {
 	return "server_invite:used_count:lua_script"
 }
 
+// Websocket
+type websocketKeys struct{}
+
+func (k websocketKeys) Session(sessionID string) (string, string) {
+	return fmt.Sprintf("discore:ws_session:%s:info", sessionID), "ws_session:id:info"
+}
+
+func (k websocketKeys) RoomSeq(room string) (string, string) {
+	return fmt.Sprintf("discore:ws_room:%s:seq", room), "ws_room:name:seq"
+}
+
+func (k websocketKeys) MessageNonce(userID snowflake.ID, nonce string) (string, string) {
+	return fmt.Sprintf("discore:ws_nonce:%d:%s", userID, nonce), "ws_nonce:user_id:nonce"
+}
+
+func (k websocketKeys) Connections(userID snowflake.ID) (string, string) {
+	return fmt.Sprintf("discore:ws_conn:%d:active", userID), "ws_conn:user_id:active"
+}
+
+// Presence
+type presenceKeys struct{}
+
+func (k presenceKeys) Sessions(userID snowflake.ID) (string, string) {
+	return fmt.Sprintf("discore:presence:%d:sessions", userID), "presence:user_id:sessions"
+}
+
+func (k presenceKeys) Status(userID snowflake.ID) (string, string) {
+	return fmt.Sprintf("discore:presence:%d:status", userID), "presence:user_id:status"
+}
+
 // Usage
 var Keys = struct {
 	User         userKeys
 	Server       serverKeys
 	Channel      channelKeys
+	Member       memberKeys
 	ServerInvite serverInviteKeys
+	Websocket    websocketKeys
+	Presence     presenceKeys
 }{}
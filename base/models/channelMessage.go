@@ -8,16 +8,40 @@ import (
 
 // Message represents a message in a server channel
 type ChannelMessage struct {
-	ID        snowflake.ID `bson:"_id,omitempty" json:"id"` //Snowflake ID
-	Content   string       `bson:"content" json:"content"`
-	FileURL   *string      `bson:"file_url,omitempty" json:"fileUrl,omitempty"` // Pointer for optional field
-	UserID    snowflake.ID `bson:"user_id" json:"userID"`                       // Who sent it
-	ServerID  snowflake.ID `bson:"server_id" json:"serverID"`
-	ChannelID snowflake.ID `bson:"channel_id" json:"channelID"` // Which channel
-	Deleted   *bool        `bson:"deleted" json:"-"`
-	CreatedAt time.Time    `bson:"created_at" json:"createdAt"`
-	EditedAt  *time.Time   `bson:"edited_at" json:"editedAt"`
-	User      *User        `json:"user"` // not in db; user send
-	// Mentions:
-	// ReferencedMessageID:
+	ID        snowflake.ID     `bson:"_id,omitempty" json:"id"` //Snowflake ID
+	Content   string           `bson:"content" json:"content"`
+	FileURL   *string          `bson:"file_url,omitempty" json:"fileUrl,omitempty"` // Pointer for optional field
+	UserID    snowflake.ID     `bson:"user_id" json:"userID"`                       // Who sent it
+	ServerID  snowflake.ID     `bson:"server_id" json:"serverID"`
+	ChannelID snowflake.ID     `bson:"channel_id" json:"channelID"` // Which channel
+	Deleted   *bool            `bson:"deleted" json:"-"`
+	CreatedAt time.Time        `bson:"created_at" json:"createdAt"`
+	EditedAt  *time.Time       `bson:"edited_at" json:"editedAt"`
+	User      *User            `json:"user"`                     // not in db; user send
+	Nonce     string           `bson:"-" json:"nonce,omitempty"` // not in db; client idempotency key echoed back
+	Mentions  *MessageMentions `bson:"mentions,omitempty" json:"mentions,omitempty"`
+	Reactions []*Reaction      `bson:"reactions,omitempty" json:"reactions,omitempty"` // per-emoji counts
+	PinnedAt  *time.Time       `bson:"pinned_at,omitempty" json:"pinnedAt,omitempty"`
+	PinnedBy  *snowflake.ID    `bson:"pinned_by,omitempty" json:"pinnedBy,omitempty"`
+
+	// Thread of the message; reply references its parent, parent carries the reply stats
+	ReferencedMessageID *snowflake.ID     `bson:"referenced_message_id,omitempty" json:"referencedMessageID,omitempty"`
+	ReferencedMessage   *MessageReference `bson:"-" json:"referencedMessage,omitempty"` // not in db; parent to render the reply
+	ReplyCount          int64             `bson:"reply_count,omitempty" json:"replyCount"`
+	LastReplyAt         *time.Time        `bson:"last_reply_at,omitempty" json:"lastReplyAt,omitempty"`
+}
+
+// Mentions of the message checked against the server membership
+type MessageMentions struct {
+	UserIDs  []snowflake.ID `bson:"user_ids,omitempty" json:"userIDs,omitempty"`
+	Roles    []MemberRole   `bson:"roles,omitempty" json:"roles,omitempty"`
+	Everyone bool           `bson:"everyone,omitempty" json:"everyone,omitempty"`
+}
+
+// Referenced parent of the reply
+type MessageReference struct {
+	ID      snowflake.ID `json:"id"`
+	UserID  snowflake.ID `json:"userID"`
+	Content string       `json:"content"`
+	User    *User        `json:"user,omitempty"`
 }
@@ -17,4 +17,9 @@ type DirectMessage struct {
 	CreatedAt      time.Time    `bson:"created_at" json:"createdAt"`
 	UpdatedAt      *time.Time   `bson:"updated_at" json:"updatedAt"`
 	User           *User        `json:"user"`
+	Reactions      []*Reaction  `bson:"reactions,omitempty" json:"reactions,omitempty"` // per-emoji counts
+
+	// Pin of the message; cleared by the unpin or delete
+	PinnedAt *time.Time    `bson:"pinned_at,omitempty" json:"pinnedAt,omitempty"`
+	PinnedBy *snowflake.ID `bson:"pinned_by,omitempty" json:"pinnedBy,omitempty"`
 }
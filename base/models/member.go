@@ -26,4 +26,5 @@ type Member struct {
 	DeletedAt      *time.Time `db:"deleted_at" json:"-"`
 	User           *User      `json:"user"` // not in db; used in join
 	InviteCodeUsed *string    `db:"invite_code_used" json:"-"`
+	Status         string     `db:"-" json:"status,omitempty"` // not in db; user presence
 }
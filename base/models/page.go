@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/bwmarrin/snowflake"
+)
+
+// Direction of the message history page from the cursor
+type PageDirection string
+
+const (
+	PageBefore PageDirection = "before" // older than the cursor; latest messages without the cursor
+	PageAfter  PageDirection = "after"  // newer than the cursor
+	PageAround PageDirection = "around" // cursor message with its older and newer halves
+)
+
+// Message history page request
+type PageQuery struct {
+	Direction PageDirection
+	Cursor    *snowflake.ID
+	Limit     int64
+}
+
+// Metadata of the message history page; messages are always latest first
+type PageInfo struct {
+	HasMore    bool          `json:"hasMore"` // more in the paged direction; either side for around
+	HasOlder   bool          `json:"hasOlder"`
+	HasNewer   bool          `json:"hasNewer"`
+	PrevCursor *snowflake.ID `json:"prevCursor"` // before cursor of the older page
+	NextCursor *snowflake.ID `json:"nextCursor"` // after cursor of the newer page
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Reaction count of the emoji on the message; me if the requesting user reacted
+type Reaction struct {
+	Emoji string `bson:"emoji" json:"emoji"`
+	Count int64  `bson:"count" json:"count"`
+	Me    bool   `bson:"-" json:"me"`
+}
+
+// Reaction of the user on the channel or direct message
+type MessageReaction struct {
+	MessageID snowflake.ID `bson:"message_id"`
+	Emoji     string       `bson:"emoji"`
+	UserID    snowflake.ID `bson:"user_id"`
+	CreatedAt time.Time    `bson:"created_at"`
+}
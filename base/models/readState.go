@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Read state of the user in the channel or conversation; unread and mention counts are capped
+type ReadState struct {
+	ID           snowflake.ID `bson:"target_id" json:"id"` // channel or conversation
+	UserID       snowflake.ID `bson:"user_id" json:"-"`
+	LastReadID   snowflake.ID `bson:"last_read_id" json:"lastReadID"`
+	UpdatedAt    time.Time    `bson:"updated_at" json:"-"`
+	UnreadCount  int64        `bson:"-" json:"unreadCount"`
+	MentionCount int64        `bson:"-" json:"mentionCount"`
+}
@@ -52,8 +52,12 @@ func CreateSession(ctx context.Context, session *models.UserSession) *appError.E
                                    ip_address, expires_at)
         VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING *`
+	// Caller may assign the id up front; e.g. carried in the tokens
+	if session.ID == 0 {
+		session.ID = utils.GenerateSnowflakeID()
+	}
 	err := database.PostgresDB.GetContext(ctx, session, querySessionInsert,
-		utils.GenerateSnowflakeID(),
+		session.ID,
 		session.UserID,
 		session.RefreshToken,
 		session.DeviceInfo,
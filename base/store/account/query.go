@@ -71,3 +71,26 @@ func GetUserByEmail(ctx context.Context, email string) (*models.User, *appError.
 
 	return &user, nil
 }
+
+// Check the user session is not signed out or expired
+func HasUserSession(ctx context.Context, sessionID snowflake.ID, userID snowflake.ID) (bool, *appError.Error) {
+	var exists bool
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_sessions
+			WHERE id = $1
+			  AND user_id = $2
+			  AND expires_at > NOW()
+		)
+	`
+
+	err := database.PostgresDB.GetContext(ctx, &exists, query, sessionID, userID)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to check user session")
+		return false, appError.NewInternal("Failed to check user session")
+	}
+
+	return exists, nil
+}
@@ -1,6 +1,7 @@
 package channelStore
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 
@@ -9,12 +10,11 @@ import (
 	"github.com/himanshu3889/discore-backend/base/models"
 
 	"github.com/bwmarrin/snowflake"
-	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 // Get channel by ID
-func GetChannelByID(ctx *gin.Context, channelID snowflake.ID) (*models.Channel, *appError.Error) {
+func GetChannelByID(ctx context.Context, channelID snowflake.ID) (*models.Channel, *appError.Error) {
 	query := `
 		SELECT *
 		FROM channels c
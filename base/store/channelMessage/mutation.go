@@ -2,16 +2,26 @@ package channelMessageStore
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/himanshu3889/discore-backend/base/databases"
 	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	modelsLib "github.com/himanshu3889/discore-backend/base/lib/models"
 	"github.com/himanshu3889/discore-backend/base/models"
+	pinStore "github.com/himanshu3889/discore-backend/base/store/pin"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/bwmarrin/snowflake"
 	"github.com/sirupsen/logrus"
 )
 
+// Mongo duplicate key error; message with the same id already inserted
+const duplicateKeyErrorCode = 11000
+
 // Create message in the database
 func CreateChannelMessage(ctx context.Context, msg *models.ChannelMessage) (*models.ChannelMessage, *appError.Error) {
 	// Set server-side fields
@@ -27,6 +37,11 @@ func CreateChannelMessage(ctx context.Context, msg *models.ChannelMessage) (*mod
 		logrus.Error("Message ID is required to create message")
 		return nil, appError.NewBadRequest("message ID is required")
 	}
+	content, appErr := modelsLib.ValidMessageContent(msg.Content)
+	if appErr != nil {
+		return nil, appErr
+	}
+	msg.Content = content
 	if msg.Content == "" && msg.FileURL == nil {
 		logrus.Error("message must have content or file to create message")
 		return nil, appError.NewBadRequest("message must have content or file")
@@ -46,6 +61,9 @@ func CreateChannelMessage(ctx context.Context, msg *models.ChannelMessage) (*mod
 
 	// Insert into database
 	_, err := database.MongoDB.Collection("channel_messages").InsertOne(ctx, msg)
+	if mongo.IsDuplicateKeyError(err) {
+		return msg, nil // Replayed; already inserted with the same id
+	}
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"content_length": len(msg.Content),
@@ -72,7 +90,9 @@ func CreateChannelMessagesBulk(ctx context.Context, msgs []*models.ChannelMessag
 		msg.Deleted = &deleted
 
 		// Validate required fields
-		if msg.ID == 0 || (msg.Content == "" && msg.FileURL == nil) || msg.ServerID == 0 || msg.ChannelID == 0 || msg.UserID == 0 {
+		content, contentErr := modelsLib.ValidMessageContent(msg.Content)
+		msg.Content = content
+		if contentErr != nil || msg.ID == 0 || (msg.Content == "" && msg.FileURL == nil) || msg.ServerID == 0 || msg.ChannelID == 0 || msg.UserID == 0 {
 			// Record the original index of the invalid message
 			failedMsgIndices = append(failedMsgIndices, i)
 			continue
@@ -92,11 +112,19 @@ func CreateChannelMessagesBulk(ctx context.Context, msgs []*models.ChannelMessag
 
 	if err != nil {
 		if bulkErr, ok := err.(mongo.BulkWriteException); ok {
-			logrus.Warnf("Partial DB insert: %d messages failed", len(bulkErr.WriteErrors))
 			// Match the MongoDB errors back to the original msgs index
+			failedWrites := 0
 			for _, writeErr := range bulkErr.WriteErrors {
+				// Replayed message; already inserted with the same id so idempotent
+				if writeErr.Code == duplicateKeyErrorCode {
+					continue
+				}
 				originalIndex := validToOriginalIndex[writeErr.Index]
 				failedMsgIndices = append(failedMsgIndices, originalIndex)
+				failedWrites++
+			}
+			if failedWrites > 0 {
+				logrus.Warnf("Partial DB insert: %d messages failed", failedWrites)
 			}
 			return failedMsgIndices, nil
 		}
@@ -107,3 +135,202 @@ func CreateChannelMessagesBulk(ctx context.Context, msgs []*models.ChannelMessag
 
 	return failedMsgIndices, nil
 }
+
+// Update the message content with its resolved mentions; returns the edited message
+func UpdateChannelMessageContent(ctx context.Context, channelID, messageID snowflake.ID, content string, mentions *models.MessageMentions) (*models.ChannelMessage, *appError.Error) {
+	content, appErr := modelsLib.ValidMessageContent(content)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if content == "" {
+		return nil, appError.NewBadRequest("message content is required")
+	}
+
+	filter := bson.M{
+		"_id":        messageID,
+		"channel_id": channelID,
+		"deleted":    false,
+	}
+	set := bson.M{
+		"content":   content,
+		"edited_at": time.Now().UTC(),
+	}
+	update := bson.M{"$set": set}
+	if mentions != nil {
+		set["mentions"] = mentions
+	} else {
+		update["$unset"] = bson.M{"mentions": ""}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var message models.ChannelMessage
+	err := database.MongoDB.Collection("channel_messages").FindOneAndUpdate(ctx, filter, update, opts).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithFields(logrus.Fields{
+			"channel_id": channelID,
+			"message_id": messageID,
+		}).WithError(err).Error("Failed to update message in channel messages")
+		return nil, appError.NewInternal("Failed to update the message in channel messages")
+	}
+	// Edited message carries its author like the history
+	attachMessageUsers(ctx, channelID, []*models.ChannelMessage{&message})
+	return &message, nil
+}
+
+// Soft delete the message; returns the message before the delete, pinned if the delete unpinned it.
+// The delete and the pin count are written in one transaction
+func DeleteChannelMessage(ctx context.Context, channelID, messageID snowflake.ID) (*models.ChannelMessage, *appError.Error) {
+	filter := bson.M{
+		"_id":        messageID,
+		"channel_id": channelID,
+		"deleted":    false,
+	}
+	// Deleted message leaves the pins
+	update := bson.M{
+		"$set":   bson.M{"deleted": true},
+		"$unset": bson.M{"pinned_at": "", "pinned_by": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var message models.ChannelMessage
+	err := database.WithMongoTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		message = models.ChannelMessage{}
+		err := database.MongoDB.Collection("channel_messages").FindOneAndUpdate(sessCtx, filter, update, opts).Decode(&message)
+		if err != nil || message.PinnedAt == nil {
+			return err
+		}
+		return pinStore.DecrementPins(sessCtx, channelID)
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithFields(logrus.Fields{
+			"channel_id": channelID,
+			"message_id": messageID,
+		}).WithError(err).Error("Failed to delete message in channel messages")
+		return nil, appError.NewInternal("Failed to delete the message in channel messages")
+	}
+	return &message, nil
+}
+
+// Recount the reply stats of the thread parents; idempotent so replayed and deleted replies stay correct
+func RefreshThreadStats(ctx context.Context, parentIDs []snowflake.ID) *appError.Error {
+	if len(parentIDs) == 0 {
+		return nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.M{
+			"referenced_message_id": bson.M{"$in": parentIDs},
+			"deleted":               false,
+		}}},
+		{{"$group", bson.M{
+			"_id":           "$referenced_message_id",
+			"reply_count":   bson.M{"$sum": 1},
+			"last_reply_at": bson.M{"$max": "$created_at"},
+		}}},
+	}
+
+	collection := database.MongoDB.Collection("channel_messages")
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		logrus.WithField("parent_ids", parentIDs).WithError(err).Error("Failed to count the thread replies")
+		return appError.NewInternal("Failed to count the thread replies")
+	}
+	defer cursor.Close(ctx)
+
+	var stats []struct {
+		ParentID    snowflake.ID `bson:"_id"`
+		ReplyCount  int64        `bson:"reply_count"`
+		LastReplyAt time.Time    `bson:"last_reply_at"`
+	}
+	if err = cursor.All(ctx, &stats); err != nil {
+		logrus.WithField("parent_ids", parentIDs).WithError(err).Error("Failed to count the thread replies")
+		return appError.NewInternal("Failed to count the thread replies")
+	}
+
+	// Parents without the replies are reset
+	updates := make([]mongo.WriteModel, 0, len(parentIDs))
+	counted := make(map[snowflake.ID]bool, len(stats))
+	for _, stat := range stats {
+		counted[stat.ParentID] = true
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": stat.ParentID}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"reply_count":   stat.ReplyCount,
+				"last_reply_at": stat.LastReplyAt,
+			}}))
+	}
+	for _, parentID := range parentIDs {
+		if counted[parentID] {
+			continue
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": parentID}).
+			SetUpdate(bson.M{"$unset": bson.M{"reply_count": "", "last_reply_at": ""}}))
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	if _, err := collection.BulkWrite(ctx, updates, opts); err != nil {
+		logrus.WithField("parent_ids", parentIDs).WithError(err).Error("Failed to update the thread stats")
+		return appError.NewInternal("Failed to update the thread stats")
+	}
+	return nil
+}
+
+// Transaction abort of the pin already in that state
+var errPinUnchanged = errors.New("pin unchanged")
+
+// Pin or unpin the message; returns the message and false if it was already in that state.
+// Pin is bad request once the channel has max pins; the pin and the pin count are written in one transaction
+func SetChannelMessagePinned(ctx context.Context, channelID, messageID, userID snowflake.ID, pinned bool, maxPins int64) (*models.ChannelMessage, bool, *appError.Error) {
+	collection := database.MongoDB.Collection("channel_messages")
+
+	filter := bson.M{
+		"_id":        messageID,
+		"channel_id": channelID,
+		"deleted":    false,
+		"pinned_at":  bson.M{"$exists": !pinned},
+	}
+	update := bson.M{"$unset": bson.M{"pinned_at": "", "pinned_by": ""}}
+	if pinned {
+		update = bson.M{"$set": bson.M{"pinned_at": time.Now().UTC(), "pinned_by": userID}}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var message models.ChannelMessage
+	err := database.WithMongoTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		message = models.ChannelMessage{}
+		// Already pinned or unpinned is checked before the cap
+		err := collection.FindOneAndUpdate(sessCtx, filter, update, opts).Decode(&message)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errPinUnchanged
+		}
+		if err != nil {
+			return err
+		}
+		if pinned {
+			return pinStore.IncrementPins(sessCtx, channelID, maxPins)
+		}
+		return pinStore.DecrementPins(sessCtx, channelID)
+	})
+	switch {
+	case err == nil:
+		return &message, true, nil
+	case errors.Is(err, errPinUnchanged):
+		// Not found if the message is gone
+		current, appErr := GetChannelMessage(ctx, channelID, messageID)
+		return current, false, appErr
+	case errors.Is(err, pinStore.ErrLimit):
+		return nil, false, appError.NewBadRequest(fmt.Sprintf("Channel can have at most %d pins", maxPins))
+	}
+	logrus.WithFields(logrus.Fields{
+		"channel_id": channelID,
+		"message_id": messageID,
+	}).WithError(err).Error("Failed to update the message pin")
+	return nil, false, appError.NewInternal("Failed to update the message pin")
+}
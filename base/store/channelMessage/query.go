@@ -6,30 +6,78 @@ import (
 
 	"github.com/himanshu3889/discore-backend/base/databases"
 	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	paginationLib "github.com/himanshu3889/discore-backend/base/lib/pagination"
 	"github.com/himanshu3889/discore-backend/base/models"
 	userStore "github.com/himanshu3889/discore-backend/base/store/user"
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Get channel last messages
-func GetServerChannelLastMessages(ctx context.Context, serverID, channelID snowflake.ID, limit int64, afterID *snowflake.ID) ([]*models.ChannelMessage, *appError.Error) {
-	// Cap the limit
-	if limit > 100 {
-		limit = 100
+// Get the page of the channel messages; latest first
+func GetChannelMessagesPage(ctx context.Context, channelID snowflake.ID, page *models.PageQuery) ([]*models.ChannelMessage, *models.PageInfo, *appError.Error) {
+	filter := bson.M{
+		"channel_id": channelID,
+		"deleted":    false,
 	}
 
+	messages, pageInfo, err := paginationLib.Find(ctx, database.MongoDB.Collection("channel_messages"), filter, page, func(message *models.ChannelMessage) snowflake.ID {
+		return message.ID
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"channel_id": channelID,
+			"direction":  page.Direction,
+			"limit":      page.Limit,
+		}).WithError(err).Error("Failed to fetch messages from database")
+		return nil, nil, appError.NewInternal("Failed to fetch messages from database")
+	}
+
+	attachMessageUsers(ctx, channelID, messages)
+	attachReferencedMessages(ctx, channelID, messages)
+
+	return messages, pageInfo, nil
+}
+
+// Get the channel message if not deleted
+func GetChannelMessage(ctx context.Context, channelID, messageID snowflake.ID) (*models.ChannelMessage, *appError.Error) {
 	filter := bson.M{
+		"_id":        messageID,
 		"channel_id": channelID,
 		"deleted":    false,
 	}
 
-	// If you have a cursor, get messages before it
-	if afterID != nil {
-		filter["_id"] = bson.M{"$lt": afterID}
+	var message models.ChannelMessage
+	err := database.MongoDB.Collection("channel_messages").FindOne(ctx, filter).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithFields(logrus.Fields{
+			"channel_id": channelID,
+			"message_id": messageID,
+		}).WithError(err).Error("Failed to fetch message from database")
+		return nil, appError.NewInternal("Failed to fetch message from database")
+	}
+	return &message, nil
+}
+
+// Get the thread replies of the parent; latest first, before the cursor if given
+func GetChannelThreadMessages(ctx context.Context, parent *models.ChannelMessage, limit int64, beforeID *snowflake.ID) ([]*models.ChannelMessage, *appError.Error) {
+	// Cap the limit
+	if limit > 100 {
+		limit = 100
+	}
+
+	filter := bson.M{
+		"referenced_message_id": parent.ID,
+		"deleted":               false,
+	}
+	if beforeID != nil {
+		filter["_id"] = bson.M{"$lt": beforeID}
 	}
 
 	opts := options.Find()
@@ -39,27 +87,74 @@ func GetServerChannelLastMessages(ctx context.Context, serverID, channelID snowf
 	cursor, err := database.MongoDB.Collection("channel_messages").Find(ctx, filter, opts)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
-			"channel_id": channelID,
-			"limit":      limit,
-		}).WithError(err).Error("Failed to fetch messages from database")
-		return nil, appError.NewInternal("Failed to fetch messages from database")
+			"channel_id": parent.ChannelID,
+			"parent_id":  parent.ID,
+		}).WithError(err).Error("Failed to fetch thread messages from database")
+		return nil, appError.NewInternal("Failed to fetch thread messages from database")
 	}
-
 	defer cursor.Close(ctx)
 
-	var messages []*models.ChannelMessage
+	messages := []*models.ChannelMessage{}
 	if err = cursor.All(ctx, &messages); err != nil {
 		logrus.WithFields(logrus.Fields{
-			"channel_id": channelID,
-			"limit":      limit,
-		}).WithError(err).Error("Failed to fetch messages from database")
-		return nil, appError.NewInternal("Failed to fetch messages from database")
+			"channel_id": parent.ChannelID,
+			"parent_id":  parent.ID,
+		}).WithError(err).Error("Failed to fetch thread messages from database")
+		return nil, appError.NewInternal("Failed to fetch thread messages from database")
+	}
+
+	// Parent author in the same batch
+	attachMessageUsers(ctx, parent.ChannelID, append(messages, parent))
+	for _, msg := range messages {
+		msg.ReferencedMessage = referenceOf(parent)
+	}
+
+	return messages, nil
+}
+
+// Get the pinned messages of the channel; latest pinned first
+func GetChannelPins(ctx context.Context, channelID snowflake.ID, limit int64) ([]*models.ChannelMessage, *appError.Error) {
+	filter := bson.M{
+		"channel_id": channelID,
+		"deleted":    false,
+		"pinned_at":  bson.M{"$exists": true},
+	}
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{"pinned_at", -1}})
+	opts.SetLimit(limit)
+
+	cursor, err := database.MongoDB.Collection("channel_messages").Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithField("channel_id", channelID).WithError(err).Error("Failed to fetch pinned messages from database")
+		return nil, appError.NewInternal("Failed to fetch pinned messages from database")
 	}
+	defer cursor.Close(ctx)
 
-	if len(messages) == 0 {
-		messages = []*models.ChannelMessage{}
+	messages := []*models.ChannelMessage{}
+	if err = cursor.All(ctx, &messages); err != nil {
+		logrus.WithField("channel_id", channelID).WithError(err).Error("Failed to fetch pinned messages from database")
+		return nil, appError.NewInternal("Failed to fetch pinned messages from database")
 	}
 
+	attachMessageUsers(ctx, channelID, messages)
+	attachReferencedMessages(ctx, channelID, messages)
+
+	return messages, nil
+}
+
+// Get the parent reference of the reply; not found if the parent is deleted or of other channel
+func GetChannelMessageReference(ctx context.Context, channelID, messageID snowflake.ID) (*models.MessageReference, *appError.Error) {
+	parent, appErr := GetChannelMessage(ctx, channelID, messageID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	attachMessageUsers(ctx, channelID, []*models.ChannelMessage{parent})
+	return referenceOf(parent), nil
+}
+
+// Attach the author to each message; messages are kept without authors if users fetch fails
+func attachMessageUsers(ctx context.Context, channelID snowflake.ID, messages []*models.ChannelMessage) {
 	// Extract unique user IDs
 	userIDSet := make(map[snowflake.ID]bool)
 	for _, msg := range messages {
@@ -87,6 +182,99 @@ func GetServerChannelLastMessages(ctx context.Context, serverID, channelID snowf
 			msg.User = usersMap[msg.UserID]
 		}
 	}
+}
 
-	return messages, nil
+// Attach the parent reference to each reply; deleted parents are left out
+func attachReferencedMessages(ctx context.Context, channelID snowflake.ID, messages []*models.ChannelMessage) {
+	parentIDSet := make(map[snowflake.ID]bool)
+	for _, msg := range messages {
+		if msg.ReferencedMessageID != nil {
+			parentIDSet[*msg.ReferencedMessageID] = true
+		}
+	}
+	if len(parentIDSet) == 0 {
+		return
+	}
+
+	parentIDs := make([]snowflake.ID, 0, len(parentIDSet))
+	for id := range parentIDSet {
+		parentIDs = append(parentIDs, id)
+	}
+
+	filter := bson.M{
+		"_id":        bson.M{"$in": parentIDs},
+		"channel_id": channelID,
+		"deleted":    false,
+	}
+	cursor, err := database.MongoDB.Collection("channel_messages").Find(ctx, filter)
+	if err != nil {
+		logrus.WithField("channel_id", channelID).WithError(err).Warn("Failed to fetch referenced messages")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var parents []*models.ChannelMessage
+	if err = cursor.All(ctx, &parents); err != nil {
+		logrus.WithField("channel_id", channelID).WithError(err).Warn("Failed to fetch referenced messages")
+		return
+	}
+	attachMessageUsers(ctx, channelID, parents)
+
+	references := make(map[snowflake.ID]*models.MessageReference, len(parents))
+	for _, parent := range parents {
+		references[parent.ID] = referenceOf(parent)
+	}
+	for _, msg := range messages {
+		if msg.ReferencedMessageID != nil {
+			msg.ReferencedMessage = references[*msg.ReferencedMessageID]
+		}
+	}
+}
+
+// Reference of the parent message
+func referenceOf(parent *models.ChannelMessage) *models.MessageReference {
+	return &models.MessageReference{
+		ID:      parent.ID,
+		UserID:  parent.UserID,
+		Content: parent.Content,
+		User:    parent.User,
+	}
+}
+
+// Count the messages of the others after the last read; counting stops at the limit
+func CountChannelUnread(ctx context.Context, channelID, userID, lastReadID snowflake.ID, limit int64) (int64, *appError.Error) {
+	filter := bson.M{
+		"channel_id": channelID,
+		"deleted":    false,
+		"_id":        bson.M{"$gt": lastReadID},
+		"user_id":    bson.M{"$ne": userID},
+	}
+	return countChannelMessages(ctx, channelID, filter, limit)
+}
+
+// Count the messages mentioning the user, its role or everyone after the last read; counting stops at the limit
+func CountChannelMentions(ctx context.Context, channelID, userID snowflake.ID, role models.MemberRole, lastReadID snowflake.ID, limit int64) (int64, *appError.Error) {
+	filter := bson.M{
+		"channel_id": channelID,
+		"deleted":    false,
+		"_id":        bson.M{"$gt": lastReadID},
+		"user_id":    bson.M{"$ne": userID},
+		"$or": bson.A{
+			bson.M{"mentions.user_ids": userID},
+			bson.M{"mentions.roles": role},
+			bson.M{"mentions.everyone": true},
+		},
+	}
+	return countChannelMessages(ctx, channelID, filter, limit)
+}
+
+// Count the channel messages of the filter; range of the snowflake ids on the channel index
+func countChannelMessages(ctx context.Context, channelID snowflake.ID, filter bson.M, limit int64) (int64, *appError.Error) {
+	opts := options.Count().SetLimit(limit)
+	count, err := database.MongoDB.Collection("channel_messages").CountDocuments(ctx, filter, opts)
+	if err != nil {
+		logrus.WithField("channel_id", channelID).WithError(err).Error("Failed to count the channel messages")
+		return 0, appError.NewInternal("Failed to count the channel messages")
+	}
+	return count, nil
 }
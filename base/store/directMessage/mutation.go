@@ -2,12 +2,21 @@ package directMessageStore
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/himanshu3889/discore-backend/base/databases"
 	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	modelsLib "github.com/himanshu3889/discore-backend/base/lib/models"
 	"github.com/himanshu3889/discore-backend/base/models"
+	pinStore "github.com/himanshu3889/discore-backend/base/store/pin"
 
+	"github.com/bwmarrin/snowflake"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Create message in the database
@@ -21,6 +30,11 @@ func CreateDirectMessage(ctx context.Context, msg *models.DirectMessage) *appErr
 		logrus.Error("Message ID is required to create message")
 		return appError.NewBadRequest("message ID is required")
 	}
+	content, appErr := modelsLib.ValidMessageContent(msg.Content)
+	if appErr != nil {
+		return appErr
+	}
+	msg.Content = content
 	if msg.Content == "" && msg.FileURL == nil {
 		logrus.Error("message must have content or file to create message")
 		return appError.NewBadRequest("message must have content or file")
@@ -49,3 +63,134 @@ func CreateDirectMessage(ctx context.Context, msg *models.DirectMessage) *appErr
 
 	return nil
 }
+
+// Update the message content; returns the edited message
+func UpdateDirectMessageContent(ctx context.Context, conversationID, messageID snowflake.ID, content string) (*models.DirectMessage, *appError.Error) {
+	content, appErr := modelsLib.ValidMessageContent(content)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if content == "" {
+		return nil, appError.NewBadRequest("message content is required")
+	}
+
+	filter := bson.M{
+		"_id":             messageID,
+		"conversation_id": conversationID,
+		"deleted":         false,
+	}
+	update := bson.M{"$set": bson.M{
+		"content":    content,
+		"updated_at": time.Now().UTC(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var message models.DirectMessage
+	err := database.MongoDB.Collection("direct_messages").FindOneAndUpdate(ctx, filter, update, opts).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithFields(logrus.Fields{
+			"conversation_id": conversationID,
+			"message_id":      messageID,
+		}).WithError(err).Error("Failed to update message in direct messages")
+		return nil, appError.NewInternal("Failed to update the message in direct messages")
+	}
+	// Edited message carries its author like the history
+	attachMessageUsers(ctx, conversationID, []*models.DirectMessage{&message})
+	return &message, nil
+}
+
+// Soft delete the message; returns the message before the delete, pinned if the delete unpinned it.
+// The delete and the pin count are written in one transaction
+func DeleteDirectMessage(ctx context.Context, conversationID, messageID snowflake.ID) (*models.DirectMessage, *appError.Error) {
+	filter := bson.M{
+		"_id":             messageID,
+		"conversation_id": conversationID,
+		"deleted":         false,
+	}
+	// Deleted message leaves the pins
+	update := bson.M{
+		"$set": bson.M{
+			"deleted":    true,
+			"updated_at": time.Now().UTC(),
+		},
+		"$unset": bson.M{"pinned_at": "", "pinned_by": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var message models.DirectMessage
+	err := database.WithMongoTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		message = models.DirectMessage{}
+		err := database.MongoDB.Collection("direct_messages").FindOneAndUpdate(sessCtx, filter, update, opts).Decode(&message)
+		if err != nil || message.PinnedAt == nil {
+			return err
+		}
+		return pinStore.DecrementPins(sessCtx, conversationID)
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithFields(logrus.Fields{
+			"conversation_id": conversationID,
+			"message_id":      messageID,
+		}).WithError(err).Error("Failed to delete message in direct messages")
+		return nil, appError.NewInternal("Failed to delete the message in direct messages")
+	}
+	return &message, nil
+}
+
+// Transaction abort of the pin already in that state
+var errPinUnchanged = errors.New("pin unchanged")
+
+// Pin or unpin the message; returns the message and false if it was already in that state.
+// Pin is bad request once the conversation has max pins; the pin and the pin count are written in one transaction
+func SetDirectMessagePinned(ctx context.Context, conversationID, messageID, userID snowflake.ID, pinned bool, maxPins int64) (*models.DirectMessage, bool, *appError.Error) {
+	collection := database.MongoDB.Collection("direct_messages")
+
+	filter := bson.M{
+		"_id":             messageID,
+		"conversation_id": conversationID,
+		"deleted":         false,
+		"pinned_at":       bson.M{"$exists": !pinned},
+	}
+	update := bson.M{"$unset": bson.M{"pinned_at": "", "pinned_by": ""}}
+	if pinned {
+		update = bson.M{"$set": bson.M{"pinned_at": time.Now().UTC(), "pinned_by": userID}}
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var message models.DirectMessage
+	err := database.WithMongoTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		message = models.DirectMessage{}
+		// Already pinned or unpinned is checked before the cap
+		err := collection.FindOneAndUpdate(sessCtx, filter, update, opts).Decode(&message)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return errPinUnchanged
+		}
+		if err != nil {
+			return err
+		}
+		if pinned {
+			return pinStore.IncrementPins(sessCtx, conversationID, maxPins)
+		}
+		return pinStore.DecrementPins(sessCtx, conversationID)
+	})
+	switch {
+	case err == nil:
+		return &message, true, nil
+	case errors.Is(err, errPinUnchanged):
+		// Not found if the message is gone
+		current, appErr := GetDirectMessage(ctx, conversationID, messageID)
+		return current, false, appErr
+	case errors.Is(err, pinStore.ErrLimit):
+		return nil, false, appError.NewBadRequest(fmt.Sprintf("Conversation can have at most %d pins", maxPins))
+	}
+	logrus.WithFields(logrus.Fields{
+		"conversation_id": conversationID,
+		"message_id":      messageID,
+	}).WithError(err).Error("Failed to update the direct message pin")
+	return nil, false, appError.NewInternal("Failed to update the direct message pin")
+}
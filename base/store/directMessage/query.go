@@ -7,56 +7,127 @@ import (
 
 	database "github.com/himanshu3889/discore-backend/base/databases"
 	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	paginationLib "github.com/himanshu3889/discore-backend/base/lib/pagination"
 	"github.com/himanshu3889/discore-backend/base/models"
 	userStore "github.com/himanshu3889/discore-backend/base/store/user"
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Get channel last messages
-func GetConversationLastMessages(ctx context.Context, conversationID snowflake.ID, limit int64, afterID *snowflake.ID) ([]*models.DirectMessage, *appError.Error) {
-	// Cap the limit
-	if limit > 100 {
-		limit = 100
+// Get the page of the conversation messages; latest first
+func GetConversationMessagesPage(ctx context.Context, conversationID snowflake.ID, page *models.PageQuery) ([]*models.DirectMessage, *models.PageInfo, *appError.Error) {
+	filter := bson.M{
+		"conversation_id": conversationID,
+		"deleted":         false,
+	}
+
+	messages, pageInfo, err := paginationLib.Find(ctx, database.MongoDB.Collection("direct_messages"), filter, page, func(message *models.DirectMessage) snowflake.ID {
+		return message.ID
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"conversation_id": conversationID,
+			"direction":       page.Direction,
+			"limit":           page.Limit,
+		}).WithError(err).Error("Failed to fetch direct messages from database")
+		return nil, nil, appError.NewInternal("Failed to fetch direct messages")
+	}
+
+	attachMessageUsers(ctx, conversationID, messages)
+
+	return messages, pageInfo, nil
+}
+
+// conversation is valid only if the user is a participant
+func HasValidConversationForUser(ctx context.Context, conversationID, userID snowflake.ID) (bool, *appError.Error) {
+	if conversationID == 0 || userID == 0 {
+		logrus.Error("Conversation ID and User ID are required")
+		return false, appError.NewBadRequest("conversation ID and user ID are required")
+	}
+
+	query := `
+		SELECT EXISTS(SELECT 1
+		FROM conversations
+		WHERE id = $1 
+		  AND (user1_id = $2 OR user2_id = $2)
+		)
+	`
+
+	var valid bool
+	err := database.PostgresDB.GetContext(ctx, &valid, query, conversationID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil // Not a participant or doesn't exist
+		}
+		logrus.WithFields(logrus.Fields{
+			"conversation_id": conversationID,
+			"user_id":         userID,
+		}).WithError(err).Error("Failed to fetch conversation for user")
+		return false, appError.NewInternal("failed to fetch conversation")
 	}
 
+	return valid, nil
+}
+
+// Get the direct message if not deleted
+func GetDirectMessage(ctx context.Context, conversationID, messageID snowflake.ID) (*models.DirectMessage, *appError.Error) {
 	filter := bson.M{
+		"_id":             messageID,
 		"conversation_id": conversationID,
 		"deleted":         false,
 	}
 
-	// If you have a cursor, get messages before it
-	if afterID != nil {
-		filter["_id"] = bson.M{"$lt": afterID}
+	var message models.DirectMessage
+	err := database.MongoDB.Collection("direct_messages").FindOne(ctx, filter).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithFields(logrus.Fields{
+			"conversation_id": conversationID,
+			"message_id":      messageID,
+		}).WithError(err).Error("Failed to fetch direct message from database")
+		return nil, appError.NewInternal("Failed to fetch direct message")
+	}
+	return &message, nil
+}
+
+// Get the pinned messages of the conversation; latest pinned first
+func GetConversationPins(ctx context.Context, conversationID snowflake.ID, limit int64) ([]*models.DirectMessage, *appError.Error) {
+	filter := bson.M{
+		"conversation_id": conversationID,
+		"deleted":         false,
+		"pinned_at":       bson.M{"$exists": true},
 	}
 
 	opts := options.Find()
-	opts.SetSort(bson.D{{"_id", -1}})
+	opts.SetSort(bson.D{{"pinned_at", -1}})
 	opts.SetLimit(limit)
 
 	cursor, err := database.MongoDB.Collection("direct_messages").Find(ctx, filter, opts)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"conversation_id": conversationID,
-			"limit":           limit,
-		}).WithError(err).Error("Failed to fetch direct messages from database")
-		return nil, appError.NewInternal("Failed to fetch direct messages")
+		logrus.WithField("conversation_id", conversationID).WithError(err).Error("Failed to fetch pinned direct messages from database")
+		return nil, appError.NewInternal("Failed to fetch pinned direct messages")
 	}
-
 	defer cursor.Close(ctx)
 
-	var messages []*models.DirectMessage
+	messages := []*models.DirectMessage{}
 	if err = cursor.All(ctx, &messages); err != nil {
-		return nil, appError.NewInternal("Failed to fetch direct messages")
+		logrus.WithField("conversation_id", conversationID).WithError(err).Error("Failed to fetch pinned direct messages from database")
+		return nil, appError.NewInternal("Failed to fetch pinned direct messages")
 	}
 
-	if len(messages) == 0 {
-		messages = []*models.DirectMessage{}
-	}
+	attachMessageUsers(ctx, conversationID, messages)
 
+	return messages, nil
+}
+
+// Attach the author to each message; messages are kept without authors if users fetch fails
+func attachMessageUsers(ctx context.Context, conversationID snowflake.ID, messages []*models.DirectMessage) {
 	// Extract unique user IDs
 	userIDSet := make(map[snowflake.ID]bool)
 	for _, msg := range messages {
@@ -84,37 +155,21 @@ func GetConversationLastMessages(ctx context.Context, conversationID snowflake.I
 			msg.User = usersMap[msg.UserID]
 		}
 	}
-
-	return messages, nil
 }
 
-// conversation is valid only if the user is a participant
-func HasValidConversationForUser(ctx context.Context, conversationID, userID snowflake.ID) (bool, *appError.Error) {
-	if conversationID == 0 || userID == 0 {
-		logrus.Error("Conversation ID and User ID are required")
-		return false, appError.NewBadRequest("conversation ID and user ID are required")
+// Count the messages of the other participant after the last read; counting stops at the limit
+func CountConversationUnread(ctx context.Context, conversationID, userID, lastReadID snowflake.ID, limit int64) (int64, *appError.Error) {
+	filter := bson.M{
+		"conversation_id": conversationID,
+		"deleted":         false,
+		"_id":             bson.M{"$gt": lastReadID},
+		"user_id":         bson.M{"$ne": userID},
 	}
-
-	query := `
-		SELECT EXISTS(SELECT 1
-		FROM conversations
-		WHERE id = $1 
-		  AND (user1_id = $2 OR user2_id = $2)
-		)
-	`
-
-	var valid bool
-	err := database.PostgresDB.GetContext(ctx, &valid, query, conversationID, userID)
+	opts := options.Count().SetLimit(limit)
+	count, err := database.MongoDB.Collection("direct_messages").CountDocuments(ctx, filter, opts)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil // Not a participant or doesn't exist
-		}
-		logrus.WithFields(logrus.Fields{
-			"conversation_id": conversationID,
-			"user_id":         userID,
-		}).WithError(err).Error("Failed to fetch conversation for user")
-		return false, appError.NewInternal("failed to fetch conversation")
+		logrus.WithField("conversation_id", conversationID).WithError(err).Error("Failed to count the direct messages")
+		return 0, appError.NewInternal("Failed to count the direct messages")
 	}
-
-	return valid, nil
+	return count, nil
 }
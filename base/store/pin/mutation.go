@@ -0,0 +1,42 @@
+package pinStore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/himanshu3889/discore-backend/base/databases"
+
+	"github.com/bwmarrin/snowflake"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Pin counts of the channels and conversations; written in the transaction of the pin change
+const pinCountsCollection = "pin_counts"
+
+// Pin count of the channel or conversation is at the max pins
+var ErrLimit = errors.New("pin limit reached")
+
+// Increment the pin count of the channel or conversation; ErrLimit once it has max pins
+func IncrementPins(ctx context.Context, targetID snowflake.ID, maxPins int64) error {
+	_, err := database.MongoDB.Collection(pinCountsCollection).UpdateOne(ctx,
+		bson.M{"_id": targetID, "count": bson.M{"$lt": maxPins}},
+		bson.M{"$inc": bson.M{"count": 1}},
+		options.Update().SetUpsert(true),
+	)
+	// Count at the cap misses the filter so the upsert collides with the existing count
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLimit
+	}
+	return err
+}
+
+// Decrement the pin count of the channel or conversation; never below zero
+func DecrementPins(ctx context.Context, targetID snowflake.ID) error {
+	_, err := database.MongoDB.Collection(pinCountsCollection).UpdateOne(ctx,
+		bson.M{"_id": targetID, "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}},
+	)
+	return err
+}
@@ -0,0 +1,206 @@
+package reactionStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/himanshu3889/discore-backend/base/databases"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collections of the reacted messages
+const (
+	ChannelMessages = "channel_messages"
+	DirectMessages  = "direct_messages"
+)
+
+const reactionsCollection = "message_reactions"
+
+// Reactions of the message document
+type messageReactions struct {
+	Reactions []*models.Reaction `bson:"reactions"`
+}
+
+// Transaction aborts of the reaction writes
+var (
+	errNoChange = errors.New("reaction unchanged")
+	errRejected = errors.New("reaction rejected")
+)
+
+// Add the user reaction on the message; returns the message reactions and false if already reacted.
+// The user reaction and the emoji count are written in one transaction; new emoji is bad request once the message has max emojis
+func AddReaction(ctx context.Context, collection string, messageID, userID snowflake.ID, emoji string, maxEmojis int) ([]*models.Reaction, bool, *appError.Error) {
+	reaction := &models.MessageReaction{
+		MessageID: messageID,
+		Emoji:     emoji,
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	var reactions []*models.Reaction
+	var appErr *appError.Error
+	err := database.WithMongoTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		_, err := database.MongoDB.Collection(reactionsCollection).InsertOne(sessCtx, reaction)
+		if mongo.IsDuplicateKeyError(err) {
+			return errNoChange
+		}
+		if err != nil {
+			return err
+		}
+
+		reactions, appErr, err = incrementReaction(sessCtx, collection, messageID, emoji, maxEmojis)
+		if err != nil {
+			return err
+		}
+		if appErr != nil {
+			return errRejected
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return reactions, true, nil
+	case errors.Is(err, errNoChange):
+		reactions, appErr := GetReactions(ctx, collection, messageID)
+		return reactions, false, appErr
+	case errors.Is(err, errRejected):
+		return nil, false, appErr
+	}
+	logrus.WithFields(logrus.Fields{
+		"message_id": messageID,
+		"user_id":    userID,
+	}).WithError(err).Error("Failed to add the message reaction")
+	return nil, false, appError.NewInternal("Failed to add the reaction")
+}
+
+// Remove the user reaction on the message; returns the message reactions and false if never reacted.
+// The user reaction and the emoji count are written in one transaction
+func RemoveReaction(ctx context.Context, collection string, messageID, userID snowflake.ID, emoji string) ([]*models.Reaction, bool, *appError.Error) {
+	var reactions []*models.Reaction
+	err := database.WithMongoTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		filter := bson.M{"message_id": messageID, "emoji": emoji, "user_id": userID}
+		result, err := database.MongoDB.Collection(reactionsCollection).DeleteOne(sessCtx, filter)
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return errNoChange
+		}
+
+		reactions, err = decrementReaction(sessCtx, collection, messageID, emoji)
+		return err
+	})
+	switch {
+	case err == nil:
+		return reactions, true, nil
+	case errors.Is(err, errNoChange):
+		reactions, appErr := GetReactions(ctx, collection, messageID)
+		return reactions, false, appErr
+	}
+	logrus.WithFields(logrus.Fields{
+		"message_id": messageID,
+		"user_id":    userID,
+	}).WithError(err).Error("Failed to remove the message reaction")
+	return nil, false, appError.NewInternal("Failed to remove the reaction")
+}
+
+// Decrement the emoji count of the message; last reaction of the emoji removes the emoji
+func decrementReaction(ctx context.Context, collection string, messageID snowflake.ID, emoji string) ([]*models.Reaction, error) {
+	coll := database.MongoDB.Collection(collection)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"reactions": 1})
+
+	var message messageReactions
+	err := coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": messageID, "reactions.emoji": emoji},
+		bson.M{"$inc": bson.M{"reactions.$.count": -1}},
+		opts,
+	).Decode(&message)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*models.Reaction{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, reaction := range message.Reactions {
+		if reaction.Emoji != emoji || reaction.Count > 0 {
+			continue
+		}
+		message = messageReactions{}
+		err = coll.FindOneAndUpdate(ctx,
+			bson.M{"_id": messageID},
+			bson.M{"$pull": bson.M{"reactions": bson.M{"emoji": emoji, "count": bson.M{"$lte": 0}}}},
+			opts,
+		).Decode(&message)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+		break
+	}
+	return reactionsOf(&message), nil
+}
+
+// Increment the emoji count of the not deleted message; new emoji pushed within the cap.
+// Rejected if the message is deleted or the cap reached; error left raw so the transaction retries the conflicts
+func incrementReaction(ctx context.Context, collection string, messageID snowflake.ID, emoji string, maxEmojis int) ([]*models.Reaction, *appError.Error, error) {
+	coll := database.MongoDB.Collection(collection)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"reactions": 1})
+
+	updates := []struct {
+		filter bson.M
+		update bson.M
+	}{
+		{
+			filter: bson.M{"_id": messageID, "deleted": false, "reactions.emoji": emoji},
+			update: bson.M{"$inc": bson.M{"reactions.$.count": 1}},
+		},
+		{
+			filter: bson.M{
+				"_id":                                    messageID,
+				"deleted":                                false,
+				"reactions.emoji":                        bson.M{"$ne": emoji},
+				fmt.Sprintf("reactions.%d", maxEmojis-1): bson.M{"$exists": false},
+			},
+			update: bson.M{"$push": bson.M{"reactions": &models.Reaction{Emoji: emoji, Count: 1}}},
+		},
+	}
+
+	// Concurrent add of the same emoji is a write conflict of the transaction
+	for _, u := range updates {
+		var message messageReactions
+		err := coll.FindOneAndUpdate(ctx, u.filter, u.update, opts).Decode(&message)
+		if err == nil {
+			return reactionsOf(&message), nil, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, err
+		}
+	}
+
+	// Message deleted or the emoji cap reached
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": messageID, "deleted": false})
+	if err != nil {
+		return nil, nil, err
+	}
+	if count == 0 {
+		return nil, appError.NewNotFound("Message not found"), nil
+	}
+	return nil, appError.NewBadRequest(fmt.Sprintf("Message can have at most %d reactions", maxEmojis)), nil
+}
+
+// Reactions of the message document; empty if none
+func reactionsOf(message *messageReactions) []*models.Reaction {
+	if message.Reactions == nil {
+		return []*models.Reaction{}
+	}
+	return message.Reactions
+}
@@ -0,0 +1,65 @@
+package reactionStore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/himanshu3889/discore-backend/base/databases"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Get the reactions of the message
+func GetReactions(ctx context.Context, collection string, messageID snowflake.ID) ([]*models.Reaction, *appError.Error) {
+	opts := options.FindOne().SetProjection(bson.M{"reactions": 1})
+
+	var message messageReactions
+	err := database.MongoDB.Collection(collection).FindOne(ctx, bson.M{"_id": messageID}, opts).Decode(&message)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, appError.NewNotFound("Message not found")
+		}
+		logrus.WithField("message_id", messageID).WithError(err).Error("Failed to fetch the message reactions")
+		return nil, appError.NewInternal("Failed to fetch the message reactions")
+	}
+	return reactionsOf(&message), nil
+}
+
+// Get the emojis the user reacted with on each of the messages
+func GetUserReactions(ctx context.Context, userID snowflake.ID, messageIDs []snowflake.ID) (map[snowflake.ID]map[string]bool, *appError.Error) {
+	userReactions := make(map[snowflake.ID]map[string]bool)
+	if len(messageIDs) == 0 {
+		return userReactions, nil
+	}
+
+	filter := bson.M{
+		"user_id":    userID,
+		"message_id": bson.M{"$in": messageIDs},
+	}
+	cursor, err := database.MongoDB.Collection(reactionsCollection).Find(ctx, filter)
+	if err != nil {
+		logrus.WithField("user_id", userID).WithError(err).Error("Failed to fetch the user reactions")
+		return nil, appError.NewInternal("Failed to fetch the user reactions")
+	}
+	defer cursor.Close(ctx)
+
+	var reactions []*models.MessageReaction
+	if err = cursor.All(ctx, &reactions); err != nil {
+		logrus.WithField("user_id", userID).WithError(err).Error("Failed to fetch the user reactions")
+		return nil, appError.NewInternal("Failed to fetch the user reactions")
+	}
+
+	for _, reaction := range reactions {
+		if userReactions[reaction.MessageID] == nil {
+			userReactions[reaction.MessageID] = make(map[string]bool)
+		}
+		userReactions[reaction.MessageID][reaction.Emoji] = true
+	}
+	return userReactions, nil
+}
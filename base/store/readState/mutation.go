@@ -0,0 +1,45 @@
+package readStateStore
+
+import (
+	"context"
+	"time"
+
+	"github.com/himanshu3889/discore-backend/base/databases"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const readStatesCollection = "read_states"
+
+// Move the last read message of the user forward; returns false if already read up to it
+func Ack(ctx context.Context, userID, targetID, lastReadID snowflake.ID) (bool, *appError.Error) {
+	// Existing state at or past the message fails the upsert on the unique key; never moves back
+	filter := bson.M{
+		"user_id":      userID,
+		"target_id":    targetID,
+		"last_read_id": bson.M{"$lt": lastReadID},
+	}
+	update := bson.M{"$set": bson.M{
+		"last_read_id": lastReadID,
+		"updated_at":   time.Now().UTC(),
+	}}
+	opts := options.Update().SetUpsert(true)
+
+	result, err := database.MongoDB.Collection(readStatesCollection).UpdateOne(ctx, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"user_id":   userID,
+			"target_id": targetID,
+		}).WithError(err).Error("Failed to update the read state")
+		return false, appError.NewInternal("Failed to update the read state")
+	}
+	return result.ModifiedCount > 0 || result.UpsertedCount > 0, nil
+}
@@ -0,0 +1,43 @@
+package readStateStore
+
+import (
+	"context"
+
+	"github.com/himanshu3889/discore-backend/base/databases"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Get the read states of the user in the channels or conversations; missing if never read
+func GetReadStates(ctx context.Context, userID snowflake.ID, targetIDs []snowflake.ID) (map[snowflake.ID]*models.ReadState, *appError.Error) {
+	readStates := make(map[snowflake.ID]*models.ReadState, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return readStates, nil
+	}
+
+	filter := bson.M{
+		"user_id":   userID,
+		"target_id": bson.M{"$in": targetIDs},
+	}
+	cursor, err := database.MongoDB.Collection(readStatesCollection).Find(ctx, filter)
+	if err != nil {
+		logrus.WithField("user_id", userID).WithError(err).Error("Failed to fetch the read states")
+		return nil, appError.NewInternal("Failed to fetch the read states")
+	}
+	defer cursor.Close(ctx)
+
+	var states []*models.ReadState
+	if err = cursor.All(ctx, &states); err != nil {
+		logrus.WithField("user_id", userID).WithError(err).Error("Failed to fetch the read states")
+		return nil, appError.NewInternal("Failed to fetch the read states")
+	}
+
+	for _, state := range states {
+		readStates[state.ID] = state
+	}
+	return readStates, nil
+}
@@ -12,6 +12,7 @@ import (
 	"golang.org/x/sync/singleflight"
 
 	"github.com/bwmarrin/snowflake"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
@@ -325,3 +326,58 @@ func HasUserServerMember(ctx context.Context, userID snowflake.ID, serverID snow
 	return exists, nil
 
 }
+
+// Get the roles of the users of the list who are active members of the server
+func GetServerMemberRoles(ctx context.Context, serverID snowflake.ID, userIDs []snowflake.ID) (map[snowflake.ID]models.MemberRole, *appError.Error) {
+	roles := make(map[snowflake.ID]models.MemberRole, len(userIDs))
+	if len(userIDs) == 0 {
+		return roles, nil
+	}
+
+	const query = `SELECT user_id, role
+				   from members
+				   where server_id = $1 AND user_id = ANY($2) AND deleted_at IS NULL
+				  `
+	var members []models.Member
+	err := database.PostgresDB.SelectContext(ctx, &members, query, serverID, pq.Array(userIDs))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"server_id":    serverID,
+			"user_ids_len": len(userIDs),
+		}).WithError(err).Error("Failed to filter the server members")
+		return nil, appError.NewInternal("Failed to filter the server members")
+	}
+	for _, member := range members {
+		roles[member.UserID] = member.Role
+	}
+	return roles, nil
+}
+
+// Get the user ids of the active server members having the roles; max limit users
+func GetServerMemberUserIDsByRoles(ctx context.Context, serverID snowflake.ID, roles []models.MemberRole, limit int) ([]snowflake.ID, *appError.Error) {
+	if len(roles) == 0 {
+		return []snowflake.ID{}, nil
+	}
+
+	roleNames := make([]string, len(roles))
+	for i, role := range roles {
+		roleNames[i] = string(role)
+	}
+
+	const query = `SELECT user_id
+				   from members
+				   where server_id = $1 AND role::text = ANY($2) AND deleted_at IS NULL
+				   ORDER BY id ASC
+				   LIMIT $3
+				  `
+	memberIDs := []snowflake.ID{}
+	err := database.PostgresDB.SelectContext(ctx, &memberIDs, query, serverID, pq.Array(roleNames), limit)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"server_id": serverID,
+			"roles":     roleNames,
+		}).WithError(err).Error("Failed to get the server members by roles")
+		return nil, appError.NewInternal("Failed to get the server members by roles")
+	}
+	return memberIDs, nil
+}
@@ -2,6 +2,7 @@ package configs
 
 import (
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -64,6 +65,12 @@ type config struct {
 
 	// Rate Limiting
 	RATE_LIMIT_PER_MINUTE int
+
+	// Websocket
+	WS_SLOW_CLIENT_GRACE        time.Duration `default:"30s"` // disconnect the client behind for longer
+	WS_ALLOWED_ORIGINS          []string      // browser origins allowed to upgrade; empty allows every origin
+	WS_MAX_CONNECTIONS_PER_USER int           `default:"10"`    // live sockets of a user over every node
+	WS_MAX_NODE_CONNECTIONS     int           `default:"10000"` // sockets of this node; new upgrades are shed after
 }
 
 var Config *config
@@ -81,18 +81,22 @@ func buildSessionFromMetadata(c *gin.Context, refreshToken string) *models.UserS
 
 // Generate the user session with access and refresh tokens set in headers
 func genererateUserSession(ctx *gin.Context, user *models.User) (sess *models.UserSession, access string, refresh string, err error) {
+	// Tokens carry the session id; generated before the session is stored
+	sessionID := utils.GenerateSnowflakeID()
+
 	// Generate Access Token
-	refreshToken, err := jwtAuthentication.GenerateToken(user.Email, user.ID, jwtAuthentication.RefreshTokenValidity, jwtAuthentication.RefreshToken)
+	refreshToken, err := jwtAuthentication.GenerateToken(user.Email, user.ID, sessionID, jwtAuthentication.RefreshTokenValidity, jwtAuthentication.RefreshToken)
 	if err != nil {
 		return nil, "", "", err
 	}
 
-	accessToken, err := jwtAuthentication.GenerateToken(user.Email, user.ID, jwtAuthentication.AccessTokenValidity, jwtAuthentication.AccessToken)
+	accessToken, err := jwtAuthentication.GenerateToken(user.Email, user.ID, sessionID, jwtAuthentication.AccessTokenValidity, jwtAuthentication.AccessToken)
 	if err != nil {
 		return nil, "", "", err
 	}
 
 	session := buildSessionFromMetadata(ctx, refreshToken)
+	session.ID = sessionID
 	session.UserID = user.ID
 	appErr := accountStore.CreateSession(ctx, session)
 	if appErr != nil {
@@ -236,6 +240,9 @@ func Signout(c *gin.Context) {
 			utils.RespondWithError(c, int(appErr.Code), appErr.Message)
 			return
 		}
+		if claims.SessionId != 0 {
+			accountCacheStore.InvalidateUserSession(c, claims.SessionId)
+		}
 	}
 
 	// Clear cookies
@@ -287,7 +294,7 @@ func GetAccessTokenFromRefresh(c *gin.Context) {
 	}
 
 	// Generate NEW access token (and optionally new refresh token - token rotation)
-	newAccessToken, err := jwtAuthentication.GenerateToken(user.Email, user.ID, jwtAuthentication.AccessTokenValidity, jwtAuthentication.AccessToken)
+	newAccessToken, err := jwtAuthentication.GenerateToken(user.Email, user.ID, refreshTokenClaims.SessionId, jwtAuthentication.AccessTokenValidity, jwtAuthentication.AccessToken)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
 		return
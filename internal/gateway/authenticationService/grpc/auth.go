@@ -3,6 +3,7 @@ package authGrpc
 import (
 	"context"
 
+	accountCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/account"
 	"github.com/himanshu3889/discore-backend/configs"
 	"github.com/himanshu3889/discore-backend/internal/gateway/authenticationService/jwtAuthentication"
 	authpb "github.com/himanshu3889/discore-backend/protos/auth"
@@ -42,8 +43,26 @@ func (s *AuthServer) ValidateAccessToken(
 		return nil, status.Error(codes.Internal, "failed to parse claims")
 	}
 
+	// Signed out session; tokens without the session are valid until they expire. Cached, invalidated on signout
+	if claims.SessionId != 0 {
+		active, appErr := accountCacheStore.HasUserSession(ctx, claims.SessionId, claims.UserId)
+		if appErr != nil {
+			return nil, status.Error(codes.Internal, appErr.Message)
+		}
+		if !active {
+			return nil, status.Error(codes.Unauthenticated, "session revoked")
+		}
+	}
+
+	var expiresAt int64
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Unix()
+	}
+
 	return &authpb.ValidateAccessTokenResponse{
-		UserID: int64(claims.UserId),
-		Email:  claims.Email,
+		UserID:    int64(claims.UserId),
+		Email:     claims.Email,
+		SessionID: int64(claims.SessionId),
+		ExpiresAt: expiresAt,
 	}, nil
 }
@@ -28,10 +28,12 @@ const (
 // Claims is the struct that contains the email of the user
 // Email is the email of the user
 // RegisteredClaims is the struct that contains the registered claims of the token
+// SessionId is the user session the token belongs to; signout of the session revokes its sockets
 // ExpiresAt is the expiration time of the token
 type JwtClaims struct {
-	Email  string       `json:"email"`
-	UserId snowflake.ID `json:"user_id"`
+	Email     string       `json:"email"`
+	UserId    snowflake.ID `json:"user_id"`
+	SessionId snowflake.ID `json:"session_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -48,12 +50,13 @@ func CheckPasswordHash(password, hash string) bool {
 }
 
 // Generate JWT tokens
-func GenerateToken(email string, userId snowflake.ID, duration time.Duration, tokenType TokenType) (string, error) {
+func GenerateToken(email string, userId snowflake.ID, sessionId snowflake.ID, duration time.Duration, tokenType TokenType) (string, error) {
 	// Create claims
 	// If we didn't use the pointer, the changes we make to the struct would not be reflected in the returned value.
 	claims := &JwtClaims{
-		Email:  email,
-		UserId: userId,
+		Email:     email,
+		UserId:    userId,
+		SessionId: sessionId,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			Subject:   string(tokenType),
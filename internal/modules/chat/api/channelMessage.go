@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
-	memberCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/member"
+	accessLib "github.com/himanshu3889/discore-backend/base/lib/access"
+	channelMessageLib "github.com/himanshu3889/discore-backend/base/lib/channelMessage"
+	readStateLib "github.com/himanshu3889/discore-backend/base/lib/readState"
 	"github.com/himanshu3889/discore-backend/base/middlewares"
 	channelMessageStore "github.com/himanshu3889/discore-backend/base/store/channelMessage"
 	"github.com/himanshu3889/discore-backend/base/utils"
@@ -21,6 +23,15 @@ func registerChannelMessageRoutes(rg *gin.RouterGroup) {
 
 func channelMessageRoutes(rg *gin.RouterGroup) {
 	rg.GET("/:channelID/server/:serverID/messages", channelMessages)
+	rg.PATCH("/:channelID/messages/:messageID", editChannelMessage)
+	rg.DELETE("/:channelID/messages/:messageID", deleteChannelMessage)
+	rg.GET("/:channelID/messages/:messageID/thread", channelThreadMessages)
+	rg.PUT("/:channelID/messages/:messageID/reactions/:emoji", addChannelMessageReaction)
+	rg.DELETE("/:channelID/messages/:messageID/reactions/:emoji", removeChannelMessageReaction)
+	rg.GET("/:channelID/pins", channelPins)
+	rg.PUT("/:channelID/pins/:messageID", pinChannelMessage)
+	rg.DELETE("/:channelID/pins/:messageID", unpinChannelMessage)
+	rg.POST("/:channelID/ack/:messageID", ackChannel)
 }
 
 // Get the channel message
@@ -45,33 +56,270 @@ func channelMessages(ctx *gin.Context) {
 		return
 	}
 
-	// --- GET LIMIT, BEFORE CURSOR FROM QUERY PARAM ---
-	limitStr := ctx.DefaultQuery("limit", "50") // Default to 50 if not provided
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	page, isOk := historyPageQuery(ctx)
+	if !isOk {
+		return
+	}
+
+	if _, _, appErr := accessLib.ReadableServerChannel(ctx, userID, serverSnowID, channelSnowID); appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	messages, pageInfo, appErr := channelMessageStore.GetChannelMessagesPage(ctx, channelSnowID, page)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	channelMessageLib.MarkReactions(ctx, userID, messages)
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":  "Chat message fetched",
+		"messages": messages,
+		"page":     pageInfo,
+	})
+}
+
+// Edit the channel message content; author only
+func editChannelMessage(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	edited, appErr := channelMessageLib.EditMessage(ctx, userID, channelSnowID, messageSnowID, req.Content)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	// Connected clients update in place
+	publishRoomEvent(ctx, channelMessageUpdateEvent, channelMessageLib.Room(edited.ServerID), edited, userID)
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":        "Chat message edited",
+		"channelMessage": edited,
+	})
+}
+
+// Soft delete the channel message; author or server moderators
+func deleteChannelMessage(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	deleted, appErr := channelMessageLib.DeleteMessage(ctx, userID, channelSnowID, messageSnowID)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	publishRoomEvent(ctx, channelMessageDeleteEvent, channelMessageLib.Room(deleted.ServerID), deleted, userID)
+	if deleted.Unpinned != nil {
+		publishRoomEvent(ctx, channelPinsUpdateEvent, channelMessageLib.Room(deleted.ServerID), deleted.Unpinned, userID)
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":        "Chat message deleted",
+		"channelMessage": deleted,
+	})
+}
+
+// Channel and message ids of the path; responds the bad request if invalid
+func channelMessageParams(ctx *gin.Context) (channelID snowflake.ID, messageID snowflake.ID, ok bool) {
+	channelID, err := utils.ValidSnowflakeID(ctx.Param("channelID"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid channel ID")
+		return 0, 0, false
+	}
+	messageID, err = utils.ValidSnowflakeID(ctx.Param("messageID"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid message ID")
+		return 0, 0, false
+	}
+	return channelID, messageID, true
+}
+
+// Get the thread of the channel message; replies latest first
+func channelThreadMessages(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", "50"), 10, 64)
 	if err != nil || limit <= 0 {
 		utils.RespondWithError(ctx, http.StatusBadRequest, "Limit must be a positive number")
 		return
 	}
 
-	var afterCursor *snowflake.ID
-	if afterStr := ctx.Query("before"); afterStr != "" {
-		afterCursorSnow, err := utils.ValidSnowflakeID(afterStr)
-		if err == nil {
-			afterCursor = &afterCursorSnow
+	var beforeCursor *snowflake.ID
+	if beforeStr := ctx.Query("before"); beforeStr != "" {
+		beforeSnow, err := utils.ValidSnowflakeID(beforeStr)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid before cursor")
+			return
 		}
+		beforeCursor = &beforeSnow
+	}
+
+	thread, appErr := channelMessageLib.GetThread(ctx, userID, channelSnowID, messageSnowID, limit, beforeCursor)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	channelMessageLib.MarkReactions(ctx, userID, append(thread.Replies, thread.Parent))
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":  "Thread messages fetched",
+		"parent":   thread.Parent,
+		"messages": thread.Replies,
+	})
+}
+
+// Add the reaction of the user on the channel message
+func addChannelMessageReaction(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	result, appErr := channelMessageLib.AddReaction(ctx, userID, channelSnowID, messageSnowID, ctx.Param("emoji"))
+	respondReaction(ctx, result, appErr, "Reaction added")
+}
+
+// Remove the reaction of the user on the channel message
+func removeChannelMessageReaction(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
 	}
 
-	memberCacheStore.HasUserServerMember(ctx, userID, serverSnowID)
-	messages, appErr := channelMessageStore.GetServerChannelLastMessages(ctx, serverSnowID, channelSnowID, limit, afterCursor)
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	result, appErr := channelMessageLib.RemoveReaction(ctx, userID, channelSnowID, messageSnowID, ctx.Param("emoji"))
+	respondReaction(ctx, result, appErr, "Reaction removed")
+}
 
-	// Validate it's a valid ID
+// Get the pinned messages of the channel
+func channelPins(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, err := utils.ValidSnowflakeID(ctx.Param("channelID"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid channel ID")
+		return
+	}
+
+	pins, appErr := channelMessageLib.GetPins(ctx, userID, channelSnowID)
 	if appErr != nil {
 		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
 		return
 	}
 
+	channelMessageLib.MarkReactions(ctx, userID, pins)
+
 	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
-		"message":  "Chat message fetched",
-		"messages": messages,
+		"message":  "Pinned messages fetched",
+		"messages": pins,
 	})
 }
+
+// Pin the channel message; admins and moderators only
+func pinChannelMessage(ctx *gin.Context) {
+	setChannelMessagePinned(ctx, true)
+}
+
+// Unpin the channel message; admins and moderators only
+func unpinChannelMessage(ctx *gin.Context) {
+	setChannelMessagePinned(ctx, false)
+}
+
+// Pin or unpin the channel message and broadcast the change to the server room
+func setChannelMessagePinned(ctx *gin.Context, pinned bool) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	update, appErr := channelMessageLib.SetPinned(ctx, userID, channelSnowID, messageSnowID, pinned)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	if update.Changed {
+		publishRoomEvent(ctx, channelPinsUpdateEvent, channelMessageLib.Room(update.ServerID), update, userID)
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message": "Pins updated",
+		"pinned":  update.Pinned,
+	})
+}
+
+// Mark the channel read up to the message; synced to the other devices of the user
+func ackChannel(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	channelSnowID, messageSnowID, isOk := channelMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	update, appErr := readStateLib.AckChannel(ctx, userID, channelSnowID, messageSnowID)
+	respondAck(ctx, update, appErr)
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strconv"
 
+	directMessageLib "github.com/himanshu3889/discore-backend/base/lib/directMessage"
+	readStateLib "github.com/himanshu3889/discore-backend/base/lib/readState"
 	"github.com/himanshu3889/discore-backend/base/middlewares"
 	conversationStore "github.com/himanshu3889/discore-backend/base/store/conversation"
 	directMessageStore "github.com/himanshu3889/discore-backend/base/store/directMessage"
@@ -23,6 +25,14 @@ func conversationRoutes(rg *gin.RouterGroup) {
 	rg.GET("/:conversationID", getConversationForUser)
 	rg.GET("/all", getAllConversationForUser)
 	rg.GET("/:conversationID/messages", conversationMessagesForUser)
+	rg.PATCH("/:conversationID/messages/:messageID", editDirectMessage)
+	rg.DELETE("/:conversationID/messages/:messageID", deleteDirectMessage)
+	rg.PUT("/:conversationID/messages/:messageID/reactions/:emoji", addDirectMessageReaction)
+	rg.DELETE("/:conversationID/messages/:messageID/reactions/:emoji", removeDirectMessageReaction)
+	rg.GET("/:conversationID/pins", conversationPins)
+	rg.PUT("/:conversationID/pins/:messageID", pinDirectMessage)
+	rg.DELETE("/:conversationID/pins/:messageID", unpinDirectMessage)
+	rg.POST("/:conversationID/ack/:messageID", ackConversation)
 	rg.POST("/user/:user2ID", getOrCreateConversationForUsers)
 }
 
@@ -80,11 +90,23 @@ func getAllConversationForUser(ctx *gin.Context) {
 	conversations, appErr := conversationStore.GetAllConversationsForUser(ctx, userID, limit)
 	if appErr != nil {
 		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	conversationIDs := make([]snowflake.ID, 0, len(conversations))
+	for _, conversation := range conversations {
+		conversationIDs = append(conversationIDs, conversation.ID)
+	}
+	readStates, appErr := readStateLib.ConversationReadStates(ctx, userID, conversationIDs)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
 	}
 
 	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
 		"message":       "Conversation find",
 		"conversations": conversations,
+		"readStates":    readStates,
 	})
 }
 
@@ -127,40 +149,232 @@ func conversationMessagesForUser(ctx *gin.Context) {
 	conversationSnowID, err := utils.ValidSnowflakeID(conversationID)
 	if err != nil {
 		utils.RespondWithError(ctx, http.StatusBadRequest, err.Error())
-	}
-
-	// --- GET LIMIT, BEFORE CURSOR FROM QUERY PARAM ---
-	limitStr := ctx.DefaultQuery("limit", "50") // Default to 50 if not provided
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
-	if err != nil || limit <= 0 {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Limit must be a positive number")
 		return
 	}
 
-	var afterCursor *snowflake.ID
-	if afterStr := ctx.Query("before"); afterStr != "" {
-		channelID := ctx.Param("channelID")
-		channelSnowID, err := utils.ValidSnowflakeID(channelID)
-		if err == nil {
-			afterCursor = &channelSnowID
-		}
+	page, isOk := historyPageQuery(ctx)
+	if !isOk {
+		return
 	}
 
 	conversation, appErr := conversationStore.GetConversationForUser(ctx, conversationSnowID, userID)
 	if appErr != nil {
 		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
 	}
 
-	messages, appErr := directMessageStore.GetConversationLastMessages(ctx, conversationSnowID, limit, afterCursor)
+	messages, pageInfo, appErr := directMessageStore.GetConversationMessagesPage(ctx, conversationSnowID, page)
 
 	if appErr != nil {
 		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
 		return
 	}
 
+	directMessageLib.MarkReactions(ctx, userID, messages)
+
 	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
 		"message":      "Chat message fetched",
 		"conversation": conversation,
 		"messages":     messages,
+		"page":         pageInfo,
+	})
+}
+
+// Edit the direct message content; author only
+func editDirectMessage(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, messageSnowID, isOk := directMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	edited, appErr := directMessageLib.EditMessage(ctx, userID, conversationSnowID, messageSnowID, req.Content)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	// Both participants update in place
+	publishRoomEvent(ctx, directMessageUpdateEvent, directMessageLib.Room(edited.ConversationID), edited, userID)
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":       "Direct message edited",
+		"directMessage": edited,
+	})
+}
+
+// Soft delete the direct message; author only
+func deleteDirectMessage(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, messageSnowID, isOk := directMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	deleted, appErr := directMessageLib.DeleteMessage(ctx, userID, conversationSnowID, messageSnowID)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	publishRoomEvent(ctx, directMessageDeleteEvent, directMessageLib.Room(deleted.ConversationID), deleted, userID)
+	if deleted.Unpinned != nil {
+		publishRoomEvent(ctx, conversationPinsUpdateEvent, directMessageLib.Room(deleted.ConversationID), deleted.Unpinned, userID)
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":       "Direct message deleted",
+		"directMessage": deleted,
 	})
 }
+
+// Conversation and message ids of the path; responds the bad request if invalid
+func directMessageParams(ctx *gin.Context) (conversationID snowflake.ID, messageID snowflake.ID, ok bool) {
+	conversationID, err := utils.ValidSnowflakeID(ctx.Param("conversationID"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid conversation ID")
+		return 0, 0, false
+	}
+	messageID, err = utils.ValidSnowflakeID(ctx.Param("messageID"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid message ID")
+		return 0, 0, false
+	}
+	return conversationID, messageID, true
+}
+
+// Add the reaction of the user on the direct message
+func addDirectMessageReaction(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, messageSnowID, isOk := directMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	result, appErr := directMessageLib.AddReaction(ctx, userID, conversationSnowID, messageSnowID, ctx.Param("emoji"))
+	respondReaction(ctx, result, appErr, "Reaction added")
+}
+
+// Remove the reaction of the user on the direct message
+func removeDirectMessageReaction(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, messageSnowID, isOk := directMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	result, appErr := directMessageLib.RemoveReaction(ctx, userID, conversationSnowID, messageSnowID, ctx.Param("emoji"))
+	respondReaction(ctx, result, appErr, "Reaction removed")
+}
+
+// Get the pinned messages of the conversation
+func conversationPins(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, err := utils.ValidSnowflakeID(ctx.Param("conversationID"))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid conversation ID")
+		return
+	}
+
+	pins, appErr := directMessageLib.GetPins(ctx, userID, conversationSnowID)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	directMessageLib.MarkReactions(ctx, userID, pins)
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":  "Pinned messages fetched",
+		"messages": pins,
+	})
+}
+
+// Pin the direct message; either participant
+func pinDirectMessage(ctx *gin.Context) {
+	setDirectMessagePinned(ctx, true)
+}
+
+// Unpin the direct message; either participant
+func unpinDirectMessage(ctx *gin.Context) {
+	setDirectMessagePinned(ctx, false)
+}
+
+// Pin or unpin the direct message and broadcast the change to both participants
+func setDirectMessagePinned(ctx *gin.Context, pinned bool) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, messageSnowID, isOk := directMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	update, appErr := directMessageLib.SetPinned(ctx, userID, conversationSnowID, messageSnowID, pinned)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	if update.Changed {
+		publishRoomEvent(ctx, conversationPinsUpdateEvent, directMessageLib.Room(conversationSnowID), update, userID)
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message": "Pins updated",
+		"pinned":  update.Pinned,
+	})
+}
+
+// Mark the conversation read up to the message; synced to the other devices of the user
+func ackConversation(ctx *gin.Context) {
+	userID, _, isOk := middlewares.GetContextUserIDEmail(ctx)
+	if !isOk {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid token")
+		return
+	}
+
+	conversationSnowID, messageSnowID, isOk := directMessageParams(ctx)
+	if !isOk {
+		return
+	}
+
+	update, appErr := readStateLib.AckConversation(ctx, userID, conversationSnowID, messageSnowID)
+	respondAck(ctx, update, appErr)
+}
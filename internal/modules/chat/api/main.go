@@ -1,9 +1,43 @@
 package chatApi
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	broadcastLib "github.com/himanshu3889/discore-backend/base/lib/broadcast"
+	reactionLib "github.com/himanshu3889/discore-backend/base/lib/reaction"
+	readStateLib "github.com/himanshu3889/discore-backend/base/lib/readState"
 	"github.com/himanshu3889/discore-backend/base/middlewares"
+	"github.com/himanshu3889/discore-backend/base/models"
+	"github.com/himanshu3889/discore-backend/base/utils"
+	"github.com/himanshu3889/discore-backend/configs"
 
+	"github.com/bwmarrin/snowflake"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Room events of the message changes; same names as the websocket events
+const (
+	channelMessageUpdateEvent = "channel-message.update"
+	channelMessageDeleteEvent = "channel-message.delete"
+	directMessageUpdateEvent  = "direct-message.update"
+	directMessageDeleteEvent  = "direct-message.delete"
+
+	channelPinsUpdateEvent      = "channel.pins.update"
+	conversationPinsUpdateEvent = "conversation.pins.update"
+)
+
+// Producer of the room broadcasts of the rest api; created on the first use
+var (
+	broadcastProducer     *baseKafka.KafkaProducer
+	broadcastProducerOnce sync.Once
 )
 
 func RegisterChatRoutes(rg *gin.RouterGroup) {
@@ -12,3 +46,84 @@ func RegisterChatRoutes(rg *gin.RouterGroup) {
 	registerChannelMessageRoutes(chatGrp)
 	registerConversationRoutes(chatGrp)
 }
+
+// Get the broadcast producer
+func getBroadcastProducer() *baseKafka.KafkaProducer {
+	broadcastProducerOnce.Do(func() {
+		broadcastProducer = baseKafka.NewProducer(strings.Split(configs.Config.KAFKA_BROKERS, ","))
+	})
+	return broadcastProducer
+}
+
+// Publish the event to the room; change is already stored so only logged on failure
+func publishRoomEvent(ctx context.Context, event string, room string, payload interface{}, userID snowflake.ID) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if err := broadcastLib.Publish(ctx, getBroadcastProducer(), event, room, data, userID); err != nil {
+		logrus.WithError(err).Errorf("Failed to forward %s to broadcast topic", event)
+	}
+}
+
+// Respond the message reactions and publish the change to the room
+func respondReaction(ctx *gin.Context, result *reactionLib.Result, appErr *appError.Error, message string) {
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	reactionLib.Publish(ctx, getBroadcastProducer(), result)
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":   message,
+		"reactions": result.Reactions,
+	})
+}
+
+// Respond the read state ack and publish the change to the user devices; already read is not an error
+func respondAck(ctx *gin.Context, update *readStateLib.Update, appErr *appError.Error) {
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	if err := readStateLib.Publish(ctx, getBroadcastProducer(), update); err != nil {
+		logrus.WithError(err).Error("Failed to forward the read state to broadcast topic")
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{
+		"message":    "Read state updated",
+		"changed":    update.Changed,
+		"lastReadID": update.LastReadID,
+	})
+}
+
+// Page of the message history from the query; at most one of before, after or around. Responds the bad request if invalid
+func historyPageQuery(ctx *gin.Context) (*models.PageQuery, bool) {
+	limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Limit must be a positive number")
+		return nil, false
+	}
+
+	page := &models.PageQuery{Direction: models.PageBefore, Limit: limit}
+	for _, direction := range []models.PageDirection{models.PageBefore, models.PageAfter, models.PageAround} {
+		value := ctx.Query(string(direction))
+		if value == "" {
+			continue
+		}
+		if page.Cursor != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Only one of before, after or around is allowed")
+			return nil, false
+		}
+		cursor, err := utils.ValidSnowflakeID(value)
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid "+string(direction)+" cursor")
+			return nil, false
+		}
+		page.Direction = direction
+		page.Cursor = &cursor
+	}
+	return page, true
+}
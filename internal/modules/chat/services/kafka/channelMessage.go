@@ -7,6 +7,9 @@ import (
 	"time"
 
 	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	deliveryLib "github.com/himanshu3889/discore-backend/base/lib/delivery"
+	mentionLib "github.com/himanshu3889/discore-backend/base/lib/mention"
+	modelsLib "github.com/himanshu3889/discore-backend/base/lib/models"
 	"github.com/himanshu3889/discore-backend/base/models"
 	channelMessageStore "github.com/himanshu3889/discore-backend/base/store/channelMessage"
 
@@ -21,7 +24,7 @@ func MakeChannelMessageHandler(producer *baseKafka.KafkaProducer) func(*kafka.Me
 		// logrus.Infof("RAW JSON in channel handler consumer: %s\n", string(msg.Value))
 		metadata := baseKafka.ParseKafkaMessageHeaders(msg)
 
-		_, err := HandleChannelByteMessage(msg.Value, metadata.TraceID, metadata.UserID, metadata.IngestTime)
+		_, err := HandleChannelByteMessage(producer, msg.Value, metadata.TraceID, metadata.UserID, metadata.IngestTime)
 		return err
 	}
 }
@@ -33,34 +36,68 @@ func MakeChannelMessagesHandler(producer *baseKafka.KafkaProducer) func([]*kafka
 		var dlq []*kafka.Message
 		var validMessages []*kafka.Message
 
+		var statuses []*deliveryLib.MessageStatus
+
 		for _, msg := range messages {
 			metadata := baseKafka.ParseKafkaMessageHeaders(msg)
 			parsedMsg, err := ParseChannelByteMessage(msg.Value, metadata.TraceID, metadata.UserID, metadata.IngestTime)
 			if err != nil {
 				dlq = append(dlq, msg)
+				statuses = append(statuses, deliveryLib.StatusOfMessage(msg, deliveryLib.StatusFailed, deliveryLib.ReasonInvalidMessage))
 				continue
 			}
 			modelsToInsert = append(modelsToInsert, parsedMsg)
 			validMessages = append(validMessages, msg)
 		}
 
+		// Tell the senders; sending/sent/failed state of the client
+		ctx := context.Background()
+		defer func() {
+			if err := deliveryLib.Publish(ctx, producer, statuses); err != nil {
+				logrus.WithError(err).Error("Failed to publish the channel messages delivery status")
+			}
+		}()
+
 		if len(modelsToInsert) == 0 {
 			return nil, dlq
 		}
 
+		if appErr := mentionLib.ResolveMessages(ctx, modelsToInsert); appErr != nil {
+			logrus.Warnf("Failed to resolve the mentions: %s", appErr.Message)
+		}
+
 		// Bulk insert into the database
-		ctx := context.Background()
 		failedMsgIndices, appErr := channelMessageStore.CreateChannelMessagesBulk(ctx, modelsToInsert)
 
+		// Nothing persisted on the fatal error; every message goes to the dlq
+		if appErr != nil {
+			for _, msg := range validMessages {
+				dlq = append(dlq, msg)
+				statuses = append(statuses, deliveryLib.StatusOfMessage(msg, deliveryLib.StatusFailed, deliveryLib.ReasonPersistFailed))
+			}
+			return errors.New(appErr.Message), dlq
+		}
+
 		// Manage the dlq
+		failed := make(map[int]bool, len(failedMsgIndices))
 		for _, idx := range failedMsgIndices {
+			failed[idx] = true
 			dlq = append(dlq, validMessages[idx])
 		}
 
-		if appErr != nil {
-			return errors.New(appErr.Message), dlq
+		persisted := make([]*models.ChannelMessage, 0, len(modelsToInsert))
+		for idx, msg := range validMessages {
+			if failed[idx] {
+				statuses = append(statuses, deliveryLib.StatusOfMessage(msg, deliveryLib.StatusFailed, deliveryLib.ReasonPersistFailed))
+			} else {
+				statuses = append(statuses, deliveryLib.StatusOfMessage(msg, deliveryLib.StatusPersisted, ""))
+				persisted = append(persisted, modelsToInsert[idx])
+			}
 		}
 
+		refreshThreads(ctx, persisted)
+		publishMentions(ctx, producer, persisted)
+
 		return nil, dlq
 	}
 }
@@ -78,21 +115,77 @@ func ParseChannelByteMessage(msg []byte, ID snowflake.ID, userID snowflake.ID, c
 	incomingMessage.UserID = userID
 	incomingMessage.CreatedAt = createdAt
 
+	modelsLib.SanitizeIncomingChannelMessage(&incomingMessage)
+
+	// Unchecked mentions of the content; resolved against the membership before insert
+	incomingMessage.Mentions = mentionLib.Parse(incomingMessage.Content)
+
 	return &incomingMessage, nil
 }
 
 // Handle the raw message in the channel
-func HandleChannelByteMessage(msg []byte, ID snowflake.ID, userID snowflake.ID, createdAt time.Time) (*models.ChannelMessage, error) {
+func HandleChannelByteMessage(producer *baseKafka.KafkaProducer, msg []byte, ID snowflake.ID, userID snowflake.ID, createdAt time.Time) (*models.ChannelMessage, error) {
 	incomingMessage, err := ParseChannelByteMessage(msg, ID, userID, createdAt)
 	if err != nil {
 		return nil, err
 	}
 
 	ctx := context.Background()
+	resolveMentions(ctx, incomingMessage)
 	message, appErr := channelMessageStore.CreateChannelMessage(ctx, incomingMessage)
 	if appErr != nil {
 		return nil, errors.New(appErr.Message)
 	}
+	refreshThreads(ctx, []*models.ChannelMessage{message})
+	publishMentions(ctx, producer, []*models.ChannelMessage{message})
 	return message, nil
 
 }
+
+// Refresh the reply stats of the thread parents of the persisted replies; stats catch up on the next reply if it fails
+func refreshThreads(ctx context.Context, messages []*models.ChannelMessage) {
+	parentSet := make(map[snowflake.ID]bool)
+	parentIDs := []snowflake.ID{}
+	for _, msg := range messages {
+		if msg.ReferencedMessageID == nil || parentSet[*msg.ReferencedMessageID] {
+			continue
+		}
+		parentSet[*msg.ReferencedMessageID] = true
+		parentIDs = append(parentIDs, *msg.ReferencedMessageID)
+	}
+
+	if appErr := channelMessageStore.RefreshThreadStats(ctx, parentIDs); appErr != nil {
+		logrus.WithField("parent_ids", parentIDs).Warn("Failed to refresh the thread stats")
+	}
+}
+
+// Check the parsed mentions against the server membership; unchecked mentions are dropped on failure
+func resolveMentions(ctx context.Context, message *models.ChannelMessage) {
+	if message.Mentions == nil {
+		return
+	}
+	mentions, appErr := mentionLib.Resolve(ctx, message.ServerID, message.UserID, message.Mentions)
+	if appErr != nil {
+		logrus.WithField("message_id", message.ID).Warnf("Failed to resolve the mentions: %s", appErr.Message)
+	}
+	message.Mentions = mentions
+}
+
+// Notify the users mentioned by the persisted messages
+func publishMentions(ctx context.Context, producer *baseKafka.KafkaProducer, messages []*models.ChannelMessage) {
+	var notifications []*mentionLib.Notification
+	for _, message := range messages {
+		notification, appErr := mentionLib.NotificationOf(ctx, message)
+		if appErr != nil {
+			logrus.WithField("message_id", message.ID).Warnf("Failed to get the mention notification: %s", appErr.Message)
+			continue
+		}
+		if notification != nil {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	if err := mentionLib.Publish(ctx, producer, notifications); err != nil {
+		logrus.WithError(err).Error("Failed to publish the mention notifications")
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"net/http"
 
 	channelCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/channel"
+	memberCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/member"
 	serverCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/server"
+	presenceLib "github.com/himanshu3889/discore-backend/base/lib/presence"
+	readStateLib "github.com/himanshu3889/discore-backend/base/lib/readState"
 	"github.com/himanshu3889/discore-backend/base/middlewares"
 	"github.com/himanshu3889/discore-backend/base/models"
 	memberStore "github.com/himanshu3889/discore-backend/base/store/member"
 	serverStore "github.com/himanshu3889/discore-backend/base/store/server"
 	"github.com/himanshu3889/discore-backend/base/utils"
 
+	"github.com/bwmarrin/snowflake"
 	"github.com/gin-gonic/gin"
 )
 
@@ -123,7 +127,17 @@ func UserServerChannels(ctx *gin.Context) {
 		return
 	}
 
-	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{"server": server, "member": member, "channels": serverChannels, "message": "Server found"})
+	channelIDs := make([]snowflake.ID, 0, len(serverChannels))
+	for _, channel := range serverChannels {
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	readStates, appErr := readStateLib.ChannelReadStates(ctx, userID, member.Role, channelIDs)
+	if appErr != nil {
+		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
+		return
+	}
+
+	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{"server": server, "member": member, "channels": serverChannels, "readStates": readStates, "message": "Server found"})
 }
 
 // Get User server members; user should be member of the server
@@ -161,6 +175,30 @@ func GetServerMembers(ctx *gin.Context) {
 		return
 	}
 
+	// Attach the presence; stale presence is better than failing the members
+	memberUserIDs := make([]snowflake.ID, 0, len(members))
+	for _, member := range members {
+		memberUserIDs = append(memberUserIDs, member.UserID)
+	}
+	statuses, _ := presenceLib.GetUsersStatus(ctx, memberUserIDs)
+	for _, member := range members {
+		member.Status = string(statuses[member.UserID])
+	}
+
+	if ctx.Query("groupBy") == "presence" {
+		online := make([]*models.Member, 0, len(members))
+		offline := make([]*models.Member, 0, len(members))
+		for _, member := range members {
+			if member.Status == string(presenceLib.StatusOffline) {
+				offline = append(offline, member)
+			} else {
+				online = append(online, member)
+			}
+		}
+		utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{"server": userServer, "online": online, "offline": offline})
+		return
+	}
+
 	utils.RespondWithSuccess(ctx, http.StatusOK, gin.H{"server": userServer, "members": members})
 }
 
@@ -230,6 +268,7 @@ func CreateServer(ctx *gin.Context) {
 		utils.RespondWithError(ctx, int(appErr.Code), appErr.Message)
 		return
 	}
+	memberCacheStore.InvalidateServerMember(ctx, createdMember.UserID, createdMember.ServerID)
 
 	utils.RespondWithSuccess(ctx, http.StatusCreated, gin.H{
 		"message":  "Server created successfully",
@@ -0,0 +1,114 @@
+package websocketApp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	connectionLib "github.com/himanshu3889/discore-backend/base/lib/connection"
+	"github.com/himanshu3889/discore-backend/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxUserConnections = 10
+	defaultMaxNodeConnections = 10000
+
+	nodeFullRetryAfterSeconds = "10"
+	admissionTimeout          = 500 * time.Millisecond
+)
+
+// Reasons of the rejected upgrades; each has its own metric
+type AdmissionRejection string
+
+const (
+	AdmissionOrigin    AdmissionRejection = "origin"
+	AdmissionNodeFull  AdmissionRejection = "node_full"
+	AdmissionUserLimit AdmissionRejection = "user_limit"
+)
+
+// Check the browser origin against the allow-list; clients without the origin are not browsers
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || configs.Config == nil || len(configs.Config.WS_ALLOWED_ORIGINS) == 0 {
+		return true
+	}
+
+	for _, allowed := range configs.Config.WS_ALLOWED_ORIGINS {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	// [METRIC]
+	globalHub.MetricAdmissionRejected(AdmissionOrigin)
+	logrus.Warnf("Websocket origin not allowed: %s", origin)
+	return false
+}
+
+// Max live sockets of a user over every node
+func maxUserConnections() int {
+	if configs.Config != nil && configs.Config.WS_MAX_CONNECTIONS_PER_USER > 0 {
+		return configs.Config.WS_MAX_CONNECTIONS_PER_USER
+	}
+	return defaultMaxUserConnections
+}
+
+// Max sockets of this node
+func maxNodeConnections() int {
+	if configs.Config != nil && configs.Config.WS_MAX_NODE_CONNECTIONS > 0 {
+		return configs.Config.WS_MAX_NODE_CONNECTIONS
+	}
+	return defaultMaxNodeConnections
+}
+
+// Has node the capacity for a new socket; soft limit as the upgrades in flight are not counted
+func (hub *Hub) admitNode() bool {
+	if int(atomic.LoadInt32(&hub.totalClients)) < maxNodeConnections() {
+		return true
+	}
+	// [METRIC]
+	hub.MetricAdmissionRejected(AdmissionNodeFull)
+	return false
+}
+
+// Acquire the user connection slot; fails open on the redis errors
+func (hub *Hub) acquireConnection(userID UserID, connectionID string) bool {
+	ctx, cancel := context.WithTimeout(hub.ctx, admissionTimeout)
+	defer cancel()
+
+	acquired, appErr := connectionLib.Acquire(ctx, userID, connectionID, maxUserConnections())
+	if appErr != nil {
+		logrus.WithField("user_id", userID).Warnf("Unable to acquire the connection slot: %s", appErr.Message)
+		return true
+	}
+	if !acquired {
+		// [METRIC]
+		hub.MetricAdmissionRejected(AdmissionUserLimit)
+	}
+	return acquired
+}
+
+// Heartbeat the user connection slot; tied to the presence heartbeat
+func (hub *Hub) connectionHeartbeat(client *Client) {
+	ctx, cancel := context.WithTimeout(hub.ctx, admissionTimeout)
+	defer cancel()
+
+	if appErr := connectionLib.Heartbeat(ctx, client.userID, client.connectionID); appErr != nil {
+		logrus.WithField("user_id", client.userID).Warnf("Connection heartbeat failed: %s", appErr.Message)
+	}
+}
+
+// Release the user connection slot once disconnected
+func (hub *Hub) releaseConnection(userID UserID, connectionID string) {
+	ctx, cancel := context.WithTimeout(hub.ctx, admissionTimeout)
+	defer cancel()
+
+	if appErr := connectionLib.Release(ctx, userID, connectionID); appErr != nil {
+		logrus.WithField("user_id", userID).Warnf("Connection release failed: %s", appErr.Message)
+	}
+}
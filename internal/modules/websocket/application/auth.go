@@ -0,0 +1,215 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	accountCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/account"
+	baseDebezium "github.com/himanshu3889/discore-backend/base/infrastructure/debezium"
+	"github.com/himanshu3889/discore-backend/internal/modules/websocket/grpcService"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/gorilla/websocket"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CDC topic of the user sessions; deleted session is signed out or revoked
+const cdcUserSessionsTopic = "postgres.public.user_sessions"
+
+const (
+	CloseAuthExpired = 4001 // close code of the expired token or revoked session
+
+	tokenExpiringWindow = 5 * time.Minute // client is warned once to refresh within the window
+
+	closeReasonTokenExpired   = "token expired"
+	closeReasonSessionRevoked = "session revoked"
+)
+
+// Reason codes of the failed token refresh
+type AuthAckReason string
+
+const (
+	AuthReasonInvalidRequest AuthAckReason = "invalid_request"
+	AuthReasonInvalidToken   AuthAckReason = "invalid_token"
+	AuthReasonUserMismatch   AuthAckReason = "user_mismatch"
+	AuthReasonInternal       AuthAckReason = "internal_error"
+)
+
+// Auth outcomes of the connections; each has its own metric
+type AuthOutcome string
+
+const (
+	AuthRefreshed     AuthOutcome = "refreshed"
+	AuthRefreshFailed AuthOutcome = "refresh_failed"
+	AuthExpired       AuthOutcome = "expired"
+	AuthRevoked       AuthOutcome = "revoked"
+)
+
+// In-band token refresh of the connection
+type AuthRefreshRequest struct {
+	Token string `json:"token"`
+}
+
+// Acknowledgement of the token refresh; also sent as the expiry warning
+type AuthAck struct {
+	RequestID string        `json:"requestID,omitempty"`
+	Success   bool          `json:"success"`
+	Reason    AuthAckReason `json:"reason,omitempty"`
+	Message   string        `json:"message"`
+	ExpiresAt int64         `json:"expiresAt,omitempty"` // unix seconds of the connection token
+}
+
+// User session row of the debezium event; only the key is published
+type userSessionDebezium struct {
+	ID int64 `json:"id"`
+}
+
+// Set the token of the connection; zero expiry never expires
+func (client *Client) setToken(sessionID snowflake.ID, expiresAt int64) {
+	client.authSessionID.Store(sessionID.Int64())
+	client.tokenExpiresAt.Store(expiresAt)
+	client.expiryWarned.Store(false)
+}
+
+// Has the connection token expired
+func (client *Client) tokenExpired(now time.Time) bool {
+	expiresAt := client.tokenExpiresAt.Load()
+	return expiresAt != 0 && now.Unix() >= expiresAt
+}
+
+// Should the client be warned of the token expiry; true only once per token
+func (client *Client) tokenExpiring(now time.Time) bool {
+	expiresAt := client.tokenExpiresAt.Load()
+	if expiresAt == 0 || now.Add(tokenExpiringWindow).Unix() < expiresAt {
+		return false
+	}
+	return client.expiryWarned.CompareAndSwap(false, true)
+}
+
+// Close the connection with the auth close code; read pump exit unregisters the client
+func (client *Client) closeUnauthorized(reason string) {
+	closeMsg := websocket.FormatCloseMessage(CloseAuthExpired, reason)
+	client.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait))
+	client.close()
+}
+
+// Check the token expiry of the client; returns false if the connection is closed
+func (hub *Hub) checkClientToken(client *Client) bool {
+	now := time.Now()
+	if client.tokenExpired(now) {
+		// [METRIC]
+		hub.MetricAuth(AuthExpired)
+		client.closeUnauthorized(closeReasonTokenExpired)
+		return false
+	}
+
+	if client.tokenExpiring(now) {
+		client.sendAuthAck(EventAuthExpiring, &AuthAck{
+			Success:   true,
+			Message:   "Token is expiring, send auth.refresh",
+			ExpiresAt: client.tokenExpiresAt.Load(),
+		})
+	}
+	return true
+}
+
+// Handle the in-band token refresh; new token must be of the same user
+func (hub *Hub) handleAuthRefresh(client *Client, msg *SocketMessage) {
+	refreshFailed := func(reason AuthAckReason, message string) {
+		// [METRIC]
+		hub.MetricAuth(AuthRefreshFailed)
+		client.sendAuthAck(EventAuthRefreshFailed, &AuthAck{
+			RequestID: msg.RequestID,
+			Reason:    reason,
+			Message:   message,
+			ExpiresAt: client.tokenExpiresAt.Load(),
+		})
+	}
+
+	var req AuthRefreshRequest
+	if msg.Data == nil || json.Unmarshal(*msg.Data, &req) != nil || req.Token == "" {
+		refreshFailed(AuthReasonInvalidRequest, "Token is required")
+		return
+	}
+
+	resp, err := grpcService.ValidateToken(req.Token)
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.Unauthenticated {
+			refreshFailed(AuthReasonInvalidToken, "Invalid token")
+			return
+		}
+		logrus.WithError(err).Error("unexpected grpc error during token refresh")
+		refreshFailed(AuthReasonInternal, "Unable to validate the token")
+		return
+	}
+
+	if snowflake.ID(resp.UserID) != client.userID {
+		refreshFailed(AuthReasonUserMismatch, "Token belongs to other user")
+		return
+	}
+
+	client.setToken(snowflake.ID(resp.SessionID), resp.ExpiresAt)
+
+	// [METRIC]
+	hub.MetricAuth(AuthRefreshed)
+	client.sendAuthAck(EventAuthRefreshed, &AuthAck{
+		RequestID: msg.RequestID,
+		Success:   true,
+		Message:   "Token refreshed",
+		ExpiresAt: resp.ExpiresAt,
+	})
+}
+
+// Close the local connections of the signed out session
+func (hub *Hub) revokeAuthSession(sessionID snowflake.ID) {
+	for _, client := range hub.clientsSnapshot() {
+		if client.authSessionID.Load() != sessionID.Int64() {
+			continue
+		}
+		// [METRIC]
+		hub.MetricAuth(AuthRevoked)
+		client.closeUnauthorized(closeReasonSessionRevoked)
+	}
+}
+
+// Invalidate the cached session of the deleted row; every hub does it, the first wins
+func (hub *Hub) invalidateAuthSession(sessionID snowflake.ID) {
+	ctx, cancel := context.WithTimeout(hub.ctx, SubscribeTimeout)
+	defer cancel()
+	accountCacheStore.InvalidateUserSession(ctx, sessionID)
+}
+
+// Make handler for the user sessions CDC; deleted session closes its sockets on every node
+func makeUserSessionsCDCHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var event baseDebezium.DebeziumEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, nil
+		}
+		if event.Op != "d" || len(event.Before) == 0 {
+			return nil, nil
+		}
+
+		var session userSessionDebezium
+		if err := json.Unmarshal(event.Before, &session); err != nil || session.ID == 0 {
+			return nil, nil
+		}
+
+		hub.invalidateAuthSession(snowflake.ID(session.ID))
+		hub.revokeAuthSession(snowflake.ID(session.ID))
+		return nil, nil
+	}
+}
+
+// Send the auth ack to the client
+func (client *Client) sendAuthAck(event EventType, ack *AuthAck) {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return
+	}
+	client.sendEvent(event, "", data)
+}
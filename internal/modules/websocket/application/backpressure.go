@@ -0,0 +1,166 @@
+package websocketApp
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/himanshu3889/discore-backend/configs"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Tiered slow client outcomes; each has its own metric
+type SlowClientOutcome string
+
+const (
+	SlowClientShed       SlowClientOutcome = "shed"       // low priority events dropped
+	SlowClientResync     SlowClientOutcome = "resync"     // buffer collapsed, client asked to resync
+	SlowClientDisconnect SlowClientOutcome = "disconnect" // behind longer than the grace
+	SlowClientRecovered  SlowClientOutcome = "recovered"  // caught up again
+)
+
+const (
+	shedBufferLevel        = clientBufferSize / 2 // client is behind from this send buffer level
+	defaultSlowClientGrace = 30 * time.Second
+	slowClientResyncReason = "slow_client"
+)
+
+// Events which are shed first for a client that is behind
+var lowPriorityEvents = map[EventType]bool{
+	EventRoomTyping:     true,
+	EventRoomTypingStop: true,
+}
+
+// Frames of a batch; full batch and the batch without the low priority events
+type batchFrames struct {
+	all      *preparedFrames
+	priority *preparedFrames // nil if batch has only low priority events
+}
+
+func newBatchFrames(messages []*BroadcastRequest) *batchFrames {
+	priorityMessages := make([]*BroadcastRequest, 0, len(messages))
+	for _, msg := range messages {
+		if !lowPriorityEvents[msg.Event] {
+			priorityMessages = append(priorityMessages, msg)
+		}
+	}
+
+	frames := &batchFrames{all: newPreparedFrames(messages)}
+	switch len(priorityMessages) {
+	case 0:
+	case len(messages):
+		frames.priority = frames.all // nothing to shed
+	default:
+		frames.priority = newPreparedFrames(priorityMessages)
+	}
+	return frames
+}
+
+// How long a client may stay behind before disconnected
+func slowClientGrace() time.Duration {
+	if configs.Config != nil && configs.Config.WS_SLOW_CLIENT_GRACE > 0 {
+		return configs.Config.WS_SLOW_CLIENT_GRACE
+	}
+	return defaultSlowClientGrace
+}
+
+// Deliver the batch to the client by its backpressure tier; returns false if client should be disconnected
+func (hub *Hub) deliverBatch(client *Client, frames *batchFrames) bool {
+	// Caught up; full batch
+	if len(client.send) < shedBufferLevel {
+		if client.behindSince.Swap(0) != 0 {
+			hub.MetricSlowClient(SlowClientRecovered)
+		}
+		if preparedMsg := frames.all.forClient(client); preparedMsg != nil {
+			select {
+			case client.send <- preparedMsg:
+				return true
+			default:
+				// Filled meanwhile; handled as behind
+			}
+		}
+	}
+
+	// Behind; disconnect only after the grace
+	now := time.Now().UnixMilli()
+	client.behindSince.CompareAndSwap(0, now)
+	if time.Duration(now-client.behindSince.Load())*time.Millisecond > slowClientGrace() {
+		hub.MetricSlowClient(SlowClientDisconnect)
+		return false
+	}
+
+	// Shed the low priority events
+	if frames.priority != frames.all {
+		hub.MetricSlowClient(SlowClientShed)
+	}
+	if frames.priority == nil {
+		return true
+	}
+	preparedMsg := frames.priority.forClient(client)
+	if preparedMsg == nil {
+		return true
+	}
+
+	select {
+	case client.send <- preparedMsg:
+		return true
+	default:
+	}
+
+	// Still full; collapse the buffer, client refetches its rooms
+	hub.MetricSlowClient(SlowClientResync)
+	client.collapseSend()
+	client.sendResyncRequiredBatch(slowClientResyncReason)
+
+	select {
+	case client.send <- preparedMsg:
+	default:
+	}
+	return true
+}
+
+// Ask client to resync every subscribed room in a single frame
+func (client *Client) sendResyncRequiredBatch(reason string) {
+	data, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		return
+	}
+	raw := FrameData(data)
+
+	rooms := client.roomNames()
+	events := make([]*BroadcastRequest, 0, len(rooms))
+	for _, room := range rooms {
+		events = append(events, &BroadcastRequest{Event: EventRoomResyncRequired, Room: room, Data: &raw})
+	}
+
+	preparedMsg, err := client.codec.prepare(events)
+	if err != nil {
+		return
+	}
+	select {
+	case client.send <- preparedMsg:
+	default:
+	}
+}
+
+// Prepared message in the client codec; nil on failure
+func (frames *preparedFrames) forClient(client *Client) *websocket.PreparedMessage {
+	preparedMsg, err := frames.get(client.codec)
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to prepare batch for %s", client.codec.name)
+		return nil
+	}
+	return preparedMsg
+}
+
+// Drop every queued message of the client
+func (client *Client) collapseSend() {
+	for {
+		select {
+		case <-client.send:
+		default:
+			return
+		}
+	}
+}
@@ -1,24 +1,25 @@
 package websocketApp
 
 import (
-	"encoding/json"
 	"time"
-
-	"github.com/gorilla/websocket"
-	"github.com/sirupsen/logrus"
 )
 
-// Build the room broadcaster goroutine
-func (hub *Hub) BuildRoomBroadcaster(room string) {
+// Build the room broadcaster goroutine; returns the room state
+func (hub *Hub) BuildRoomBroadcaster(room string) *RoomState {
 	// Race condition could happen as deleting after idle so take lock
 	hub.mu.Lock()
 	defer hub.mu.Unlock()
 
-	if hub.rooms[room] == nil {
-		roomState := hub.GetOrCreateRoom(room)
-		go hub.roomBroadcaster(room, roomState)
+	roomState := hub.rooms[room]
+	if roomState == nil {
+		roomState = hub.GetOrCreateRoom(room)
+		// No new broadcaster once draining; its clients are being closed anyway
+		if !hub.draining.Load() {
+			hub.broadcasters.Go(func() { hub.roomBroadcaster(room, roomState) })
+		}
 		// logrus.Infof("Started broadcaster for room %s", room)
 	}
+	return roomState
 }
 
 // Room broadcaster; handle the room out buffer requests
@@ -85,6 +86,17 @@ func (hub *Hub) roomBroadcaster(room string, roomState *RoomState) {
 		case <-flushTicker.C:
 			flushBatchRequests()
 
+		case <-hub.drainRooms:
+			// Hub draining; flush everything queued before the clients are asked to reconnect
+			for len(roomState.outBuffer) > 0 {
+				batch = append(batch, <-roomState.outBuffer)
+				if len(batch) >= maxBatchSize {
+					flushBatchRequests()
+				}
+			}
+			flushBatchRequests()
+			return
+
 		case <-removeRoomTicker.C:
 			// check if room has any client or not
 			roomState.mu.RLock()
@@ -107,17 +119,9 @@ func (hub *Hub) broadcastRequest(broadcastRequest *BroadcastRequest, roomState *
 		return
 	}
 
-	messageBytes, err := json.Marshal(broadcastRequest)
-	if err != nil {
-		return
-	}
-
-	// Optimization: Create prepared message once (compresses once)
+	// Optimization: Create prepared message once per codec (compresses once)
 	// NOTE: can also use batching to send the messages in batch
-	preparedMsg, err := websocket.NewPreparedMessage(websocket.TextMessage, messageBytes)
-	if err != nil {
-		return
-	}
+	frames := newPreparedFrames(broadcastRequest)
 
 	// [METRIC] Start the timer before entering the critical section
 	broadcastStart := time.Now()
@@ -127,6 +131,11 @@ func (hub *Hub) broadcastRequest(broadcastRequest *BroadcastRequest, roomState *
 	var toRemove []*Client // Collect slow clients
 
 	for client := range roomState.clients {
+		preparedMsg, err := frames.get(client.codec)
+		if err != nil {
+			continue
+		}
+
 		select {
 		case client.send <- preparedMsg:
 		default:
@@ -154,24 +163,23 @@ func (hub *Hub) broadcastRequest(broadcastRequest *BroadcastRequest, roomState *
 func (hub *Hub) broadcastBatchRequest(messages []*BroadcastRequest, roomState *RoomState) {
 	// Marshal the entire ARRAY of messages
 	// Output JSON: [{"event":"msg", "data":"hi"}, {"event":"msg", "data":"hello"}]
-	batchBytes, err := json.Marshal(messages)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to marshal batch")
-		return
-	}
-
-	// Create ONE PreparedMessage (Compresses ONCE for all clients)
-	preparedMsg, err := websocket.NewPreparedMessage(websocket.TextMessage, batchBytes)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to prepare message")
-		return
-	}
+	// ONE PreparedMessage per codec (Compresses ONCE for all clients of the codec); also without the low priority events
+	frames := newBatchFrames(messages)
 
 	// [METRIC] Start the timer before entering the critical section
 	broadcastStart := time.Now()
 
-	// Lock ONCE for the entire batch
-	roomState.mu.RLock()
+	// Lock ONCE for the entire batch; history and snapshot together so resume replay never misses or duplicates
+	roomState.mu.Lock()
+
+	for _, req := range messages {
+		switch {
+		case req.seqLost:
+			roomState.history.markLost()
+		case req.Seq > 0:
+			roomState.history.push(req)
+		}
+	}
 
 	// Total count for allocation
 	count := len(roomState.clients)
@@ -183,20 +191,16 @@ func (hub *Hub) broadcastBatchRequest(messages []*BroadcastRequest, roomState *R
 		clientsSnapshot = append(clientsSnapshot, client)
 	}
 
-	roomState.mu.RUnlock()
+	roomState.mu.Unlock()
 
 	// Optimization: Pre-allocate the dead list. assume 20% will dead atmost
 	toRemove := make([]*Client, 0, count/5)
 
 	for _, client := range clientsSnapshot {
-		select {
-		case client.send <- preparedMsg:
-			// Message queued successfully
-		default:
-			// If send channel is full, assume client is slow or dead. Unregister and close connection.
+		// Tiered backpressure; shed, resync then disconnect after the grace
+		if !hub.deliverBatch(client, frames) {
 			// Mark for removal (don't remove while holding RLock)
 			toRemove = append(toRemove, client)
-			// logrus.WithFields(logrus.Fields{"addr": client.conn.RemoteAddr()}).Warn("Client send channel dead/full, unregistering")
 		}
 	}
 
@@ -239,15 +243,7 @@ func (room *RoomState) RemoveClients(clients []*Client) {
 	defer room.mu.Unlock()
 	for _, client := range clients {
 		delete(room.clients, client)
-
-		if client.conn != nil {
-			client.conn.Close() // This makes ReadMessage/WriteMessage return immediately
-		}
-
-		if client.done != nil {
-			close(client.done)
-			client.done = nil
-		}
+		client.close()
 	}
 
 }
@@ -0,0 +1,178 @@
+package websocketApp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// Websocket subprotocols; the client picks the frame codec with Sec-WebSocket-Protocol
+const (
+	SubprotocolJSON    = "discore.json.v1"
+	SubprotocolMsgpack = "discore.msgpack.v1"
+)
+
+// Server preference order of the subprotocols; selected by selectSubprotocol, not the client order
+var supportedSubprotocols = []string{SubprotocolMsgpack, SubprotocolJSON}
+
+// Frame data kept as the raw json; json frames write it verbatim, msgpack frames as its msgpack value
+type FrameData json.RawMessage
+
+// Frame codec of the client connection
+type WireCodec struct {
+	name        string
+	messageType int // websocket frame type
+	marshal     func(v interface{}) ([]byte, error)
+	unmarshal   func(data []byte, v interface{}) error
+}
+
+var (
+	jsonCodec = &WireCodec{
+		name:        SubprotocolJSON,
+		messageType: websocket.TextMessage,
+		marshal:     json.Marshal,
+		unmarshal:   json.Unmarshal,
+	}
+	msgpackCodec = &WireCodec{
+		name:        SubprotocolMsgpack,
+		messageType: websocket.BinaryMessage,
+		marshal:     msgpackMarshal,
+		unmarshal:   msgpackUnmarshal,
+	}
+)
+
+// Msgpack handle; structs by their msgpack tags, maps decoded with string keys so they convert back to the json
+var msgpackHandle = func() *codec.MsgpackHandle {
+	handle := &codec.MsgpackHandle{}
+	handle.TypeInfos = codec.NewTypeInfos([]string{"msgpack"})
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	handle.RawToString = true
+	handle.WriteExt = true // str8 and bin types of the new spec
+	return handle
+}()
+
+// Subprotocol of the client in the server preference order; empty falls back to the json
+func selectSubprotocol(requested []string) string {
+	for _, subprotocol := range supportedSubprotocols {
+		if slices.Contains(requested, subprotocol) {
+			return subprotocol
+		}
+	}
+	return ""
+}
+
+// Codec of the negotiated subprotocol; json when nothing negotiated
+func codecForSubprotocol(subprotocol string) *WireCodec {
+	switch subprotocol {
+	case SubprotocolMsgpack:
+		return msgpackCodec
+	default:
+		return jsonCodec
+	}
+}
+
+// Prepare the websocket message of the value in the codec
+func (wireCodec *WireCodec) prepare(v interface{}) (*websocket.PreparedMessage, error) {
+	data, err := wireCodec.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return websocket.NewPreparedMessage(wireCodec.messageType, data)
+}
+
+// Marshal as msgpack by the msgpack tags of the value
+func msgpackMarshal(v interface{}) ([]byte, error) {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(v); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Unmarshal the msgpack into the value by its msgpack tags
+func msgpackUnmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+// Write the raw json of the data
+func (data FrameData) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(data).MarshalJSON()
+}
+
+// Keep the raw json of the data
+func (data *FrameData) UnmarshalJSON(raw []byte) error {
+	return (*json.RawMessage)(data).UnmarshalJSON(raw)
+}
+
+// Encode the json of the data as its msgpack value; snowflake ids stay the strings of the json
+func (data *FrameData) CodecEncodeSelf(encoder *codec.Encoder) {
+	decoder := json.NewDecoder(bytes.NewReader(*data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		panic(err) // recovered by the encoder into its error
+	}
+	encoder.MustEncode(jsonNumbers(generic))
+}
+
+// Decode the msgpack value of the data into its json
+func (data *FrameData) CodecDecodeSelf(decoder *codec.Decoder) {
+	var generic interface{}
+	decoder.MustDecode(&generic)
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		panic(err) // recovered by the decoder into its error
+	}
+	*data = raw
+}
+
+// Convert the json numbers into msgpack integers or floats
+func jsonNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonNumbers(item)
+		}
+	}
+	return v
+}
+
+// Prepared messages of a batch; built once per codec used by the clients
+type preparedFrames struct {
+	payload  interface{}
+	prepared map[*WireCodec]*websocket.PreparedMessage
+}
+
+func newPreparedFrames(payload interface{}) *preparedFrames {
+	return &preparedFrames{
+		payload:  payload,
+		prepared: make(map[*WireCodec]*websocket.PreparedMessage, len(supportedSubprotocols)),
+	}
+}
+
+// Get the prepared message of the codec; not safe for concurrent use
+func (frames *preparedFrames) get(wireCodec *WireCodec) (*websocket.PreparedMessage, error) {
+	if preparedMsg, ok := frames.prepared[wireCodec]; ok {
+		return preparedMsg, nil
+	}
+	preparedMsg, err := wireCodec.prepare(frames.payload)
+	if err != nil {
+		return nil, err
+	}
+	frames.prepared[wireCodec] = preparedMsg
+	return preparedMsg, nil
+}
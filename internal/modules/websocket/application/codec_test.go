@@ -0,0 +1,26 @@
+package websocketApp
+
+import "testing"
+
+func TestSelectSubprotocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		want      string
+	}{
+		{name: "none requested", requested: nil, want: ""},
+		{name: "json only", requested: []string{SubprotocolJSON}, want: SubprotocolJSON},
+		{name: "msgpack only", requested: []string{SubprotocolMsgpack}, want: SubprotocolMsgpack},
+		{name: "server preference over the client order", requested: []string{SubprotocolJSON, SubprotocolMsgpack}, want: SubprotocolMsgpack},
+		{name: "unknown skipped", requested: []string{"chat.v2", SubprotocolJSON}, want: SubprotocolJSON},
+		{name: "only unknown", requested: []string{"chat.v2"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectSubprotocol(tt.requested); got != tt.want {
+				t.Errorf("selectSubprotocol(%v) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
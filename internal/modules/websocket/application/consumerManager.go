@@ -1,26 +1,29 @@
 package websocketApp
 
 import (
+	"context"
 	"encoding/json"
-	"os"
-	"os/signal"
+	"fmt"
 	"strings"
-	"syscall"
 	"time"
 
 	baseKafka "github.com/himanshu3889/discore-backend/base/infrastructure/kafka"
+	broadcastLib "github.com/himanshu3889/discore-backend/base/lib/broadcast"
+	deliveryLib "github.com/himanshu3889/discore-backend/base/lib/delivery"
+	mentionLib "github.com/himanshu3889/discore-backend/base/lib/mention"
+	readStateLib "github.com/himanshu3889/discore-backend/base/lib/readState"
 	"github.com/himanshu3889/discore-backend/configs"
 
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 )
 
-// Make handler for channel broadcasting
-func makeChannelBroadcastHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+// Make handler for the room broadcasting; every hub consumes the broadcast topic and delivers to its local room
+func makeRoomBroadcastHandler(hub *Hub, event EventType) func(*kafka.Message) (error, *kafka.Message) {
 	return func(msg *kafka.Message) (error, *kafka.Message) {
 		// logrus.Infof("RAW JSON in broadcasting: %s\n", string(msg.Value))
 
-		rawData := &json.RawMessage{}
+		rawData := &FrameData{}
 		err := json.Unmarshal(msg.Value, rawData)
 		if err != nil {
 			return nil, nil
@@ -28,9 +31,13 @@ func makeChannelBroadcastHandler(hub *Hub) func(*kafka.Message) (error, *kafka.M
 
 		kafkaMetadata := baseKafka.ParseKafkaMessageHeaders(msg)
 
-		msgTopic := "channel-message.add"
 		room := string(msg.Key)
 
+		// Notification stream for the members outside the room
+		if event == EventChannelMessageAdd {
+			hub.notifyChannelMessage(room, msg.Value)
+		}
+
 		// CRITICAL: Check room exists BEFORE accessing
 		hub.mu.RLock()
 		roomState, roomExists := hub.rooms[room]
@@ -41,10 +48,13 @@ func makeChannelBroadcastHandler(hub *Hub) func(*kafka.Message) (error, *kafka.M
 			return nil, nil
 		}
 
+		seq, sequenced := parseRoomSeq(msg)
 		var socketMessage = &BroadcastRequest{
-			Event:         EventType(msgTopic),
+			Event:         event,
 			Room:          room,
 			Data:          rawData,
+			Seq:           seq,
+			seqLost:       sequenced && seq == 0,
 			PipelineStart: kafkaMetadata.IngestTime,
 		}
 
@@ -59,31 +69,110 @@ func makeChannelBroadcastHandler(hub *Hub) func(*kafka.Message) (error, *kafka.M
 	}
 }
 
+// Broadcast topic of the event consumed by every hub
+func broadcastTopic(event EventType) string {
+	return broadcastLib.Topic(string(event))
+}
+
+// Consumer group of the broadcast topic for this node; each node must receive every broadcast event
+func (hub *Hub) broadcastGroupID(topic string) string {
+	return fmt.Sprintf("websocket-broadcast.%s.%s", hub.nodeID, topic)
+}
+
 // Consumer for the message broadcasting
 func (hub *Hub) KafkaBroadcastConsumer() {
-	// TODO: HOW TO STOP FROM OUTSIDE using ctx ?
 	brokers := strings.Split(configs.Config.KAFKA_BROKERS, ",")
 
-	channelBroadcastHandler := makeChannelBroadcastHandler(hub)
-	cfg := baseKafka.ConsumerConfig{
-		Brokers:     brokers,
-		GroupID:     "test-broadcast-2",
-		Topic:       "broadcast.channel-message.add",
-		AutoCommit:  false, // no auto commit
-		StartOffset: kafka.LastOffset,
+	// Room events which are fanned out through the broadcast topics
+	broadcastEvents := []EventType{
+		EventChannelMessageAdd,
+		EventChannelMessageUpdate,
+		EventChannelMessageDelete,
+		EventDirectMessageAdd,
+		EventDirectMessageUpdate,
+		EventDirectMessageDelete,
+		EventChannelPinsUpdate,
+		EventConversationPinsUpdate,
+		EventPresenceUpdate,
+		EventRoomTyping, // typing start and stop signals
+	}
+
+	for _, event := range broadcastEvents {
+		topic := broadcastTopic(event)
+		groupID := hub.broadcastGroupID(topic)
+		hub.broadcastGroups = append(hub.broadcastGroups, groupID)
+
+		cfg := baseKafka.ConsumerConfig{
+			Brokers:     brokers,
+			GroupID:     groupID,
+			Topic:       topic,
+			AutoCommit:  false, // no auto commit
+			StartOffset: kafka.LastOffset,
+		}
+		handler := makeRoomBroadcastHandler(hub, event)
+		if event == EventRoomTyping {
+			handler = makeRoomTypingHandler(hub) // per-user typing state; coalesced before the broadcast
+		}
+		hub.consumerManager.Add(cfg, handler, nil, nil)
+
+		// [METRIC]
+		hub.MetricTrackBroadcastGroup(groupID, true)
+	}
+
+	// CDC topics for the notification stream, room revocation, signed out sessions, the delivery status, mentions and reactions; every node consumes them like the broadcasts
+	topicHandlers := map[string]func(*kafka.Message) (error, *kafka.Message){
+		cdcChannelsTopic:      makeChannelsCDCHandler(hub),
+		cdcMembersTopic:       makeMembersCDCHandler(hub),
+		cdcConversationsTopic: makeConversationsCDCHandler(hub),
+		cdcUserSessionsTopic:  makeUserSessionsCDCHandler(hub),
+		deliveryLib.Topic:     makeMessageStatusHandler(hub),
+		mentionLib.Topic:      makeMentionHandler(hub),
+		reactionTopic:         makeReactionHandler(hub),
+		readStateLib.Topic:    makeReadStateHandler(hub),
 	}
-	hub.consumerManager.Add(cfg, channelBroadcastHandler, nil, nil)
+	for topic, handler := range topicHandlers {
+		groupID := hub.broadcastGroupID(topic)
+		hub.broadcastGroups = append(hub.broadcastGroups, groupID)
+
+		cfg := baseKafka.ConsumerConfig{
+			Brokers:     brokers,
+			GroupID:     groupID,
+			Topic:       topic,
+			AutoCommit:  false,
+			StartOffset: kafka.LastOffset,
+		}
+		hub.consumerManager.Add(cfg, handler, nil, nil)
 
-	// Start all
-	go hub.consumerManager.Start()
+		// [METRIC]
+		hub.MetricTrackBroadcastGroup(groupID, true)
+	}
 
-	// Wait for shutdown signal
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
+	// Start all; stopped by the hub shutdown
+	hub.consumerManager.Start()
+}
 
-	// Graceful shutdown
-	if err := hub.consumerManager.Stop(30 * time.Second); err != nil {
+// Stop the broadcast consumers and delete the node broadcast groups
+func (hub *Hub) stopBroadcastConsumer(timeout time.Duration) {
+	if err := hub.consumerManager.Stop(timeout); err != nil {
 		logrus.Error(err)
 	}
+
+	brokers := strings.Split(configs.Config.KAFKA_BROKERS, ",")
+	hub.cleanupBroadcastGroups(brokers)
+}
+
+// Delete the node ephemeral broadcast groups so they do not pile up in the kafka
+func (hub *Hub) cleanupBroadcastGroups(brokers []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := baseKafka.DeleteConsumerGroups(ctx, brokers, hub.broadcastGroups); err != nil {
+		logrus.WithError(err).Warn("Failed to delete the broadcast consumer groups")
+	}
+
+	for _, groupID := range hub.broadcastGroups {
+		// [METRIC]
+		hub.MetricTrackBroadcastGroup(groupID, false)
+	}
+	hub.broadcastGroups = nil
 }
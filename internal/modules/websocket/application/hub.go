@@ -2,7 +2,7 @@ package websocketApp
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,6 +14,7 @@ import (
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/go-redis/redis_rate/v10"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -35,17 +36,85 @@ type ChannelID = snowflake.ID
 
 // Client represents a single connected WebSocket client.
 type Client struct {
-	conn   *websocket.Conn
-	send   chan *websocket.PreparedMessage // Channel to send messages to the client; // This can panic if you try to close again
-	done   chan struct{}                   // signal to unregister the client
-	userID UserID
-	room   string //  the topic
+	conn      *websocket.Conn
+	send      chan *websocket.PreparedMessage // Channel to send messages to the client; // This can panic if you try to close again
+	done      chan struct{}                   // signal to unregister the client
+	closeOnce sync.Once                       // client can be closed from multiple rooms
+	userID    UserID
+	codec     *WireCodec      // frame codec negotiated by the subprotocol
+	sessionID string          // resumable session of the connection
+	rooms     map[string]bool // the subscribed topics
+	roomsMu   sync.RWMutex
+	servers   []snowflake.ID // joined servers for the notifications; guarded by hub notifyMu
+
+	lastActiveAt atomic.Int64 // unix millis of the last incoming message; for idle presence
+	idle         atomic.Bool  // client told it is idle
+	presenceMu   sync.Mutex   // heartbeat and disconnect of the session presence run one at a time
+	presenceGone bool         // session presence removed; guarded by presenceMu
+
+	behindSince atomic.Int64 // unix millis since the client send buffer is behind; 0 when caught up
+
+	authSessionID  atomic.Int64 // user session of the token; signout closes the connection
+	tokenExpiresAt atomic.Int64 // unix seconds of the token expiry; refreshed by auth.refresh
+	expiryWarned   atomic.Bool
+
+	connectionID string // user connection slot; released once unregistered
+
+	drain     chan struct{} // signal the write pump to flush and close; server shutdown
+	drainOnce sync.Once
+}
+
+// Create a new client for the connection
+func newClient(conn *websocket.Conn, userID UserID) *Client {
+	client := &Client{
+		conn:      conn,
+		send:      make(chan *websocket.PreparedMessage, clientBufferSize),
+		done:      make(chan struct{}),
+		drain:     make(chan struct{}),
+		userID:    userID,
+		codec:     codecForSubprotocol(conn.Subprotocol()),
+		sessionID: newSessionID(),
+		rooms:     make(map[string]bool),
+	}
+	client.markActive()
+	return client
+}
+
+// Has client subscribed the room
+func (client *Client) inRoom(room string) bool {
+	client.roomsMu.RLock()
+	defer client.roomsMu.RUnlock()
+	return client.rooms[room]
+}
+
+// Snapshot of the client subscribed rooms
+func (client *Client) roomNames() []string {
+	client.roomsMu.RLock()
+	defer client.roomsMu.RUnlock()
+	names := make([]string, 0, len(client.rooms))
+	for name := range client.rooms {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Close the connection and signal the pumps; safe to call many times
+func (client *Client) close() {
+	client.closeOnce.Do(func() {
+		if client.conn != nil {
+			client.conn.Close() // This makes ReadMessage/WriteMessage return immediately
+		}
+		close(client.done)
+	})
 }
 
 // Subscriptions
 type RoomRequest struct {
-	client *Client
-	name   string // room:unique_id
+	client    *Client
+	name      string // room:unique_id
+	requestID string // client correlation id for the ack
+	resume    bool   // replay the missed events after lastSeq
+	lastSeq   uint64
 }
 
 // RoomState holds per-room data with its own lock
@@ -55,16 +124,21 @@ type RoomState struct {
 	outBuffer chan *BroadcastRequest // for broadcasting
 	mu        sync.RWMutex           // Per-room lock
 	typing    TypingCoalescer
+	reactions ReactionCoalescer
+	history   *eventRing // sequenced events for the resume replay; guarded by mu
+	ctx       context.Context
 }
 
 type BroadcastRequest struct {
-	Event  EventType        `json:"event"`
-	Room   string           `json:"room"`
-	Data   *json.RawMessage `json:"data"`
-	Action *string          `json:"action"`
+	Event   EventType  `json:"event" msgpack:"event"`
+	Room    string     `json:"room" msgpack:"room"`
+	Data    *FrameData `json:"data" msgpack:"data"`
+	Action  *string    `json:"action" msgpack:"action"`
+	Seq     uint64     `json:"seq,omitempty" msgpack:"seq,omitempty"` // per-room sequence; 0 for the unsequenced events
+	seqLost bool       // sequenced event without the seq; room history can not be replayed across it
 
 	// Internal: When did this message enter the system?
-	PipelineStart time.Time `json:"-"`
+	PipelineStart time.Time `json:"-" msgpack:"-"`
 }
 
 // Constants for buffer and queue managements
@@ -73,12 +147,15 @@ const (
 	unregisterBufferLen = 100
 	roomOutBufferLen    = 100
 	clientBufferSize    = 20
+	maxClientRooms      = 25 // max subscribed rooms per connection
 )
 
 // Hub maintains the set of active clients and broadcasts messages to them.
 type Hub struct {
 	rooms        map[string]*RoomState // Room states
+	clients      map[*Client]bool      // registered clients; for the shutdown drain
 	totalClients int32                 // total client connections
+	draining     atomic.Bool           // shutting down; no new upgrades
 
 	register   chan *Client // Register clients to the hub
 	unregister chan *Client // Unregister client from hub; cleanup its stuff from room
@@ -89,28 +166,50 @@ type Hub struct {
 
 	limiter *redis_rate.Limiter // Rate limiting
 
-	ctx context.Context // context
-	wg  sync.WaitGroup  // wait group
-	mu  sync.RWMutex    // Locking
+	// Notification stream indexes
+	serverClients map[snowflake.ID]map[*Client]bool // server -> local clients of the members
+	userClients   map[UserID]map[*Client]bool       // user -> local sessions
+	notifyMu      sync.RWMutex
+
+	nodeID          string   // unique per hub process; used for the broadcast consumer groups
+	broadcastGroups []string // ephemeral consumer groups of this node
+
+	ctx          context.Context    // context; cancelled once the hub is shut down
+	cancel       context.CancelFunc // cancel the hub context
+	drainRooms   chan struct{}      // signal the room broadcasters to flush and exit
+	broadcasters sync.WaitGroup     // running room broadcasters
+	wg           sync.WaitGroup     // wait group of the client cleanup tasks
+	mu           sync.RWMutex       // Locking
 }
 
 // NewHub creates and returns a new Hub instance.
-func newHub() *Hub {
+func newHub(ctx context.Context) *Hub {
 	brokers := strings.Split(configs.Config.KAFKA_BROKERS, ",")
 	redisClient := redisDatabase.RedisClient
+	hubCtx, cancel := context.WithCancel(ctx)
 
 	return &Hub{
 		rooms:           make(map[string]*RoomState),
+		clients:         make(map[*Client]bool),
+		serverClients:   make(map[snowflake.ID]map[*Client]bool),
+		userClients:     make(map[UserID]map[*Client]bool),
 		register:        make(chan *Client, registerBufferLen),
 		unregister:      make(chan *Client, unregisterBufferLen),
 		producer:        baseKafka.NewProducer(brokers),
 		consumerManager: baseKafka.NewConsumerManager("websocket-hub"),
 		limiter:         redis_rate.NewLimiter(redisClient),
-		ctx:             context.Background(),
-		wg:              sync.WaitGroup{},
+		nodeID:          newNodeID(),
+		ctx:             hubCtx,
+		cancel:          cancel,
+		drainRooms:      make(chan struct{}),
 	}
 }
 
+// Unique node id for the hub; machine id along with random suffix so restarted nodes never share a group
+func newNodeID() string {
+	return fmt.Sprintf("%d-%s", configs.Config.MACHINE_ID, uuid.NewString()[:8])
+}
+
 // Global hub instance
 var (
 	globalHub *Hub
@@ -120,8 +219,7 @@ var (
 // Initialize websocket hub
 func InitializeHub(ctx context.Context) {
 	once.Do(func() {
-		// hubCtx, cancel := context.WithCancel(ctx)
-		globalHub = newHub()
+		globalHub = newHub(ctx)
 
 		// Start the hub's event loop
 		go globalHub.run()
@@ -130,22 +228,38 @@ func InitializeHub(ctx context.Context) {
 
 // run starts the hub's main event loop.
 func (hub *Hub) run() {
-	logrus.Info("Running websocket hub...")
+	logrus.Infof("Running websocket hub `%s`...", hub.nodeID)
 
 	// Start the consumers
-	go hub.KafkaBroadcastConsumer()
+	hub.KafkaBroadcastConsumer()
 
 	for {
 		select {
+		case <-hub.ctx.Done(): // hub shut down
+			return
 
-		case _, ok := <-hub.register: // to register the client; not room joining
+		case client, ok := <-hub.register: // to register the client; not room joining
 			if !ok {
 				// Closed
 				return
 			}
 
+			hub.mu.Lock()
+			hub.clients[client] = true
+			hub.mu.Unlock()
 			atomic.AddInt32(&hub.totalClients, 1)
 
+			// Registered while draining; ask to reconnect to other node
+			if hub.draining.Load() {
+				client.startDrain()
+			}
+
+			go hub.presenceHeartbeat(client)
+
+			// Notification stream of the user
+			hub.indexUserClient(client)
+			go hub.indexClientServers(client)
+
 			// [METRIC]
 			hub.MetricTrackConnect(true)
 
@@ -156,15 +270,29 @@ func (hub *Hub) run() {
 				// Closed
 				return
 			}
-			// Safely access
-			hub.mu.RLock()
-			roomState := hub.rooms[client.room]
-			hub.mu.RUnlock()
-
-			if roomState != nil {
-				roomState.RemoveClients([]*Client{client})
+			// Keep the session for the resume window; shutdown waits for these
+			hub.wg.Go(func() { hub.saveSession(client, sessionResumeWindow) })
+			hub.wg.Go(func() { hub.presenceDisconnect(client) })
+			hub.wg.Go(func() { hub.releaseConnection(client.userID, client.connectionID) })
+
+			// Cleanup every subscribed room; safely access
+			clientRooms := client.roomNames()
+			hub.stopTyping(client.userID, clientRooms)
+			for _, roomName := range clientRooms {
+				hub.mu.RLock()
+				roomState := hub.rooms[roomName]
+				hub.mu.RUnlock()
+
+				if roomState != nil {
+					roomState.RemoveClients([]*Client{client})
+				}
 			}
+			client.close()
+			hub.unindexClient(client)
 
+			hub.mu.Lock()
+			delete(hub.clients, client)
+			hub.mu.Unlock()
 			atomic.AddInt32(&hub.totalClients, -1)
 
 			// [METRIC]
@@ -187,9 +315,10 @@ func (hub *Hub) NewRoomState(name string) *RoomState {
 		name:      name,
 		clients:   make(map[*Client]bool),
 		outBuffer: make(chan *BroadcastRequest, roomOutBufferLen),
-		typing: TypingCoalescer{
-			typers: make(map[UserID]bool),
-		},
+		history:   newEventRing(roomHistoryLen),
+		typing:    newTypingCoalescer(),
+		reactions: newReactionCoalescer(),
+		ctx:       hub.ctx,
 	}
 }
 
@@ -210,10 +339,3 @@ func (hub *Hub) GetOrCreateRoom(name string) *RoomState {
 
 	return newRoom
 }
-
-// Cleanup when hub shuts down
-func (hub *Hub) Shutdown() {
-	if hub.producer != nil {
-		hub.producer.Close()
-	}
-}
@@ -0,0 +1,125 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	deliveryLib "github.com/himanshu3889/discore-backend/base/lib/delivery"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	messageNonceWindow  = 10 * time.Minute // retries within the window are deduped
+	messageNonceMaxLen  = 64
+	messageNonceTimeout = 500 * time.Millisecond
+)
+
+// Client idempotency key of the outgoing message; clientMessageId is accepted as alias
+type MessageNonce struct {
+	Nonce           string `json:"nonce"`
+	ClientMessageID string `json:"clientMessageId"`
+}
+
+// Idempotency key of the message; empty if not sent or invalid
+func (messageNonce *MessageNonce) key() string {
+	nonce := messageNonce.Nonce
+	if nonce == "" {
+		nonce = messageNonce.ClientMessageID
+	}
+	if len(nonce) > messageNonceMaxLen {
+		return ""
+	}
+	return nonce
+}
+
+// Ack of the outgoing message with the assigned id
+type MessageAck struct {
+	RequestID string             `json:"requestID,omitempty"`
+	Nonce     string             `json:"nonce,omitempty"`
+	ID        snowflake.ID       `json:"id"`
+	Duplicate bool               `json:"duplicate,omitempty"` // retry of already accepted message
+	Reason    deliveryLib.Reason `json:"reason,omitempty"`    // failed message
+}
+
+// Claim the nonce for the message id; returns the already assigned id and false if claimed before.
+// Fails open on the redis errors
+func (hub *Hub) claimMessageNonce(userID UserID, nonce string, msgID snowflake.ID) (snowflake.ID, bool) {
+	ctx, cancel := context.WithTimeout(hub.ctx, messageNonceTimeout)
+	defer cancel()
+
+	nonceKey, _ := rediskeys.Keys.Websocket.MessageNonce(userID, nonce)
+	claimed, err := redisDatabase.RedisClient.SetNX(ctx, nonceKey, msgID.Int64(), messageNonceWindow).Result()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to claim the message nonce")
+		return msgID, true
+	}
+	if claimed {
+		return msgID, true
+	}
+
+	assignedID, err := redisDatabase.RedisClient.Get(ctx, nonceKey).Int64()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get the message nonce")
+		return msgID, true
+	}
+	return snowflake.ID(assignedID), false
+}
+
+// Release the nonce so the client retry is not deduped; e.g. publish failed
+func (hub *Hub) releaseMessageNonce(userID UserID, nonce string) {
+	ctx, cancel := context.WithTimeout(hub.ctx, messageNonceTimeout)
+	defer cancel()
+
+	nonceKey, _ := rediskeys.Keys.Websocket.MessageNonce(userID, nonce)
+	if err := redisDatabase.RedisClient.Del(ctx, nonceKey).Err(); err != nil {
+		logrus.WithError(err).Warn("Failed to release the message nonce")
+	}
+}
+
+// Make handler for the delivery status of the sent messages; sent to the sender sessions on this node
+func makeMessageStatusHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var status deliveryLib.MessageStatus
+		if err := json.Unmarshal(msg.Value, &status); err != nil {
+			return nil, nil
+		}
+
+		event := EventMessagePersisted
+		if status.Status == deliveryLib.StatusFailed {
+			event = EventMessageFailed
+		}
+		ack := &MessageAck{Nonce: status.Nonce, ID: status.ID, Reason: status.Reason}
+
+		// Sender session; every user session if it reconnected meanwhile
+		userClients := hub.userClientsSnapshot(status.UserID)
+		targets := make([]*Client, 0, len(userClients))
+		for _, client := range userClients {
+			if client.sessionID == status.SessionID {
+				targets = append(targets, client)
+			}
+		}
+		if len(targets) == 0 {
+			targets = userClients
+		}
+
+		for _, client := range targets {
+			client.sendMessageAck(event, status.Room, ack)
+		}
+		return nil, nil
+	}
+}
+
+// Send the message ack to the client
+func (client *Client) sendMessageAck(event EventType, room string, ack *MessageAck) {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return
+	}
+	client.sendEvent(event, room, data)
+}
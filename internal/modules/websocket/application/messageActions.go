@@ -0,0 +1,249 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	channelCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/channel"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	broadcastLib "github.com/himanshu3889/discore-backend/base/lib/broadcast"
+	channelMessageLib "github.com/himanshu3889/discore-backend/base/lib/channelMessage"
+	deliveryLib "github.com/himanshu3889/discore-backend/base/lib/delivery"
+	directMessageLib "github.com/himanshu3889/discore-backend/base/lib/directMessage"
+	"github.com/himanshu3889/discore-backend/base/models"
+	channelMessageStore "github.com/himanshu3889/discore-backend/base/store/channelMessage"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/sirupsen/logrus"
+)
+
+const messageActionTimeout = 3 * time.Second
+
+// Reason codes of the failed message edit or delete
+type MessageActionReason string
+
+const (
+	MessageReasonInvalid   MessageActionReason = "invalid_message"
+	MessageReasonNotFound  MessageActionReason = "not_found"
+	MessageReasonForbidden MessageActionReason = "forbidden"
+	MessageReasonInternal  MessageActionReason = "internal_error"
+)
+
+// Edit, delete or reaction of the message; channel or conversation of the message, content only for the edit
+type MessageActionRequest struct {
+	ID             snowflake.ID `json:"id"`
+	ChannelID      snowflake.ID `json:"channelID,omitempty"`
+	ConversationID snowflake.ID `json:"conversationID,omitempty"`
+	Content        string       `json:"content"`
+	Emoji          string       `json:"emoji,omitempty"` // only for the reaction
+}
+
+// Negative ack of the message edit, delete or reaction; success is the room broadcast, the reaction also gets its ack
+type MessageActionAck struct {
+	RequestID string              `json:"requestID,omitempty"`
+	ID        snowflake.ID        `json:"id"`
+	Reason    MessageActionReason `json:"reason"`
+	Message   string              `json:"message"`
+}
+
+// Reason of the failed message action by the error code
+func messageActionReason(appErr *appError.Error) MessageActionReason {
+	switch appErr.Code {
+	case appError.StatusBadRequest:
+		return MessageReasonInvalid
+	case appError.StatusNotFound:
+		return MessageReasonNotFound
+	case appError.StatusForbidden:
+		return MessageReasonForbidden
+	default:
+		return MessageReasonInternal
+	}
+}
+
+// Handle the channel message edit by the author
+func (hub *Hub) handleChannelMessageUpdate(client *Client, msg *SocketMessage) {
+	req, ok := hub.parseMessageAction(client, msg, EventChannelMessageUpdateFailed, channelOfAction)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	edited, appErr := channelMessageLib.EditMessage(ctx, client.userID, req.ChannelID, req.ID, req.Content)
+	if appErr != nil {
+		client.sendMessageActionAck(EventChannelMessageUpdateFailed, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.ID,
+			Reason:    messageActionReason(appErr),
+			Message:   appErr.Message,
+		})
+		return
+	}
+
+	hub.publishMessageAction(ctx, EventChannelMessageUpdate, channelMessageLib.Room(edited.ServerID), edited, client.userID)
+}
+
+// Handle the channel message delete by the author or a moderator
+func (hub *Hub) handleChannelMessageDelete(client *Client, msg *SocketMessage) {
+	req, ok := hub.parseMessageAction(client, msg, EventChannelMessageDeleteFailed, channelOfAction)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	deleted, appErr := channelMessageLib.DeleteMessage(ctx, client.userID, req.ChannelID, req.ID)
+	if appErr != nil {
+		client.sendMessageActionAck(EventChannelMessageDeleteFailed, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.ID,
+			Reason:    messageActionReason(appErr),
+			Message:   appErr.Message,
+		})
+		return
+	}
+
+	hub.publishMessageAction(ctx, EventChannelMessageDelete, channelMessageLib.Room(deleted.ServerID), deleted, client.userID)
+	if deleted.Unpinned != nil {
+		hub.publishMessageAction(ctx, EventChannelPinsUpdate, channelMessageLib.Room(deleted.ServerID), deleted.Unpinned, client.userID)
+	}
+}
+
+// Handle the direct message edit by the author
+func (hub *Hub) handleDirectMessageUpdate(client *Client, msg *SocketMessage) {
+	req, ok := hub.parseMessageAction(client, msg, EventDirectMessageUpdateFailed, conversationOfAction)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	edited, appErr := directMessageLib.EditMessage(ctx, client.userID, req.ConversationID, req.ID, req.Content)
+	if appErr != nil {
+		client.sendMessageActionAck(EventDirectMessageUpdateFailed, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.ID,
+			Reason:    messageActionReason(appErr),
+			Message:   appErr.Message,
+		})
+		return
+	}
+
+	hub.publishMessageAction(ctx, EventDirectMessageUpdate, directMessageLib.Room(edited.ConversationID), edited, client.userID)
+}
+
+// Handle the direct message delete by the author
+func (hub *Hub) handleDirectMessageDelete(client *Client, msg *SocketMessage) {
+	req, ok := hub.parseMessageAction(client, msg, EventDirectMessageDeleteFailed, conversationOfAction)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	deleted, appErr := directMessageLib.DeleteMessage(ctx, client.userID, req.ConversationID, req.ID)
+	if appErr != nil {
+		client.sendMessageActionAck(EventDirectMessageDeleteFailed, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.ID,
+			Reason:    messageActionReason(appErr),
+			Message:   appErr.Message,
+		})
+		return
+	}
+
+	hub.publishMessageAction(ctx, EventDirectMessageDelete, directMessageLib.Room(deleted.ConversationID), deleted, client.userID)
+	if deleted.Unpinned != nil {
+		hub.publishMessageAction(ctx, EventConversationPinsUpdate, directMessageLib.Room(deleted.ConversationID), deleted.Unpinned, client.userID)
+	}
+}
+
+// Parent reference of the reply; nil with the failed reason if the parent is missing
+func (hub *Hub) messageReference(channelID, parentID snowflake.ID) (*models.MessageReference, deliveryLib.Reason) {
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	reference, appErr := channelMessageStore.GetChannelMessageReference(ctx, channelID, parentID)
+	if appErr != nil {
+		if appErr.Code == appError.StatusNotFound {
+			return nil, deliveryLib.ReasonParentNotFound
+		}
+		logrus.WithField("parent_id", parentID).Warnf("Unable to get the replied message: %s", appErr.Message)
+		return nil, deliveryLib.ReasonPublishFailed
+	}
+	return reference, ""
+}
+
+// Failed reason of the channel message sent in the room; empty if the channel is of the room server
+func (hub *Hub) roomChannelReason(room string, serverID, channelID snowflake.ID) deliveryLib.Reason {
+	roomServerID, ok := serverIDOfRoom(room)
+	if !ok || serverID != roomServerID {
+		return deliveryLib.ReasonInvalidMessage
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	channel, appErr := channelCacheStore.GetChannelByID(ctx, channelID)
+	if appErr != nil {
+		if appErr.Code == appError.StatusNotFound {
+			return deliveryLib.ReasonChannelNotFound
+		}
+		logrus.WithField("channel_id", channelID).Warnf("Unable to get the channel: %s", appErr.Message)
+		return deliveryLib.ReasonPublishFailed
+	}
+	if channel.ServerID != roomServerID {
+		return deliveryLib.ReasonChannelNotFound
+	}
+	return ""
+}
+
+// Parse the message action request; nacked with the failed event if the message or its parent is missing
+func (hub *Hub) parseMessageAction(client *Client, msg *SocketMessage, failedEvent EventType, parentOf func(*MessageActionRequest) snowflake.ID) (*MessageActionRequest, bool) {
+	var req MessageActionRequest
+	if err := _validateClientRoomMessage(client, msg); err != nil || json.Unmarshal(*msg.Data, &req) != nil || req.ID == 0 || parentOf(&req) == 0 {
+		client.sendMessageActionAck(failedEvent, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.ID,
+			Reason:    MessageReasonInvalid,
+			Message:   "Message id and its channel or conversation id are required",
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// Parent of the channel message action
+func channelOfAction(req *MessageActionRequest) snowflake.ID {
+	return req.ChannelID
+}
+
+// Parent of the direct message action
+func conversationOfAction(req *MessageActionRequest) snowflake.ID {
+	return req.ConversationID
+}
+
+// Publish the message action to the room so every connected client updates in place
+func (hub *Hub) publishMessageAction(ctx context.Context, event EventType, room string, payload interface{}, userID UserID) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if err := broadcastLib.Publish(ctx, hub.producer, string(event), room, data, userID); err != nil {
+		logrus.WithError(err).Errorf("Failed to forward %s to broadcast topic", event)
+	}
+}
+
+// Send the message action ack to the client
+func (client *Client) sendMessageActionAck(event EventType, room string, ack *MessageActionAck) {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return
+	}
+	client.sendEvent(event, room, data)
+}
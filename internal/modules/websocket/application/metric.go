@@ -24,6 +24,15 @@ func (h *Hub) MetricTrackRoom(created bool) {
 	}
 }
 
+// trackBroadcastGroup updates the node broadcast consumer groups
+func (h *Hub) MetricTrackBroadcastGroup(groupID string, active bool) {
+	if active {
+		websocketMetrics.BroadcastConsumerGroups.WithLabelValues(h.nodeID, groupID).Set(1)
+	} else {
+		websocketMetrics.BroadcastConsumerGroups.DeleteLabelValues(h.nodeID, groupID)
+	}
+}
+
 // recordBroadcastMetrics handles the latency and throughput tracking
 func (h *Hub) MetricRecordBroadcast(eventType EventType, broadcastStart time.Time, pipelineStart time.Time) {
 
@@ -56,6 +65,40 @@ func (h *Hub) MetricSubscribeQueueDrop() {
 	websocketMetrics.SubscribeTimeouts.Inc()
 }
 
+// handles the session resume outcome; replayed, resync, expired
+func (h *Hub) MetricSessionResume(result string) {
+	websocketMetrics.SessionResumes.WithLabelValues(result).Inc()
+}
+
+// handles the slow client backpressure outcome; shed, resync, disconnect, recovered
+func (h *Hub) MetricSlowClient(outcome SlowClientOutcome) {
+	websocketMetrics.SlowClients.WithLabelValues(string(outcome)).Inc()
+}
+
+// handles the notification stream delivery; dropped if client buffer is full
+func (h *Hub) MetricNotification(event EventType, delivered bool) {
+	result := "delivered"
+	if !delivered {
+		result = "dropped"
+	}
+	websocketMetrics.Notifications.WithLabelValues(string(event), result).Inc()
+}
+
+// handles the room revoked from the clients; membership removed or conversation removed
+func (h *Hub) MetricRoomRevoked(reason RoomAckReason) {
+	websocketMetrics.RoomRevocations.WithLabelValues(string(reason)).Inc()
+}
+
+// handles the connection auth outcome; refreshed, refresh_failed, expired, revoked
+func (h *Hub) MetricAuth(outcome AuthOutcome) {
+	websocketMetrics.AuthEvents.WithLabelValues(string(outcome)).Inc()
+}
+
+// handles the rejected upgrade; origin, node_full, user_limit
+func (h *Hub) MetricAdmissionRejected(reason AdmissionRejection) {
+	websocketMetrics.AdmissionRejections.WithLabelValues(string(reason)).Inc()
+}
+
 func (h *Hub) MetricRoomTyping() {
 	websocketMetrics.TypingCoalesced.Inc()
 }
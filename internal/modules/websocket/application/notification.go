@@ -0,0 +1,369 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	baseDebezium "github.com/himanshu3889/discore-backend/base/infrastructure/debezium"
+	mentionLib "github.com/himanshu3889/discore-backend/base/lib/mention"
+	"github.com/himanshu3889/discore-backend/base/models"
+	serverStore "github.com/himanshu3889/discore-backend/base/store/server"
+	"github.com/himanshu3889/discore-backend/base/utils"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// CDC topics consumed by every hub for the notifications
+const (
+	cdcChannelsTopic = "postgres.public.channels"
+	cdcMembersTopic  = "postgres.public.members"
+)
+
+// Unread bump of the channel; sent to the server members not viewing the channel room
+type UnreadNotification struct {
+	ServerID  snowflake.ID `json:"serverID"`
+	ChannelID snowflake.ID `json:"channelID"`
+	MessageID snowflake.ID `json:"messageID"`
+	AuthorID  snowflake.ID `json:"authorID"`
+}
+
+// Mention of the user in the channel message; by the user, role or everyone
+type MentionNotification struct {
+	ServerID  snowflake.ID        `json:"serverID"`
+	ChannelID snowflake.ID        `json:"channelID"`
+	MessageID snowflake.ID        `json:"messageID"`
+	AuthorID  snowflake.ID        `json:"authorID"`
+	Roles     []models.MemberRole `json:"roles,omitempty"`
+	Everyone  bool                `json:"everyone,omitempty"`
+}
+
+// Channel created or deleted in the server
+type ChannelNotification struct {
+	ServerID  snowflake.ID `json:"serverID"`
+	ChannelID snowflake.ID `json:"channelID"`
+	Name      string       `json:"name,omitempty"`
+	Type      string       `json:"type,omitempty"`
+}
+
+// Member joined the server
+type MemberNotification struct {
+	ServerID snowflake.ID `json:"serverID"`
+	UserID   snowflake.ID `json:"userID"`
+	Role     string       `json:"role"`
+}
+
+// Channel row of the debezium event
+type channelDebezium struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	ServerID  int64   `json:"server_id"`
+	DeletedAt *string `json:"deleted_at"`
+}
+
+// Member row of the debezium event
+type memberDebezium struct {
+	ID        int64   `json:"id"`
+	Role      string  `json:"role"`
+	UserID    int64   `json:"user_id"`
+	ServerID  int64   `json:"server_id"`
+	DeletedAt *string `json:"deleted_at"`
+}
+
+// Index the client for the per-user notifications
+func (hub *Hub) indexUserClient(client *Client) {
+	hub.notifyMu.Lock()
+	defer hub.notifyMu.Unlock()
+
+	if hub.userClients[client.userID] == nil {
+		hub.userClients[client.userID] = make(map[*Client]bool)
+	}
+	hub.userClients[client.userID][client] = true
+}
+
+// Index the client for the notifications of the user joined servers
+func (hub *Hub) indexClientServers(client *Client) {
+	ctx, cancel := context.WithTimeout(hub.ctx, presenceTimeout)
+	defer cancel()
+
+	servers, appErr := serverStore.UserJoinedServers(ctx, client.userID)
+	if appErr != nil {
+		logrus.WithField("user_id", client.userID).Warnf("Unable to index the client servers: %s", appErr.Message)
+		return
+	}
+
+	serverIDs := make([]snowflake.ID, 0, len(servers))
+	for _, server := range servers {
+		serverIDs = append(serverIDs, server.ID)
+	}
+	hub.addClientsServers([]*Client{client}, serverIDs)
+}
+
+// Add the clients in the server notification index; skip the clients already gone
+func (hub *Hub) addClientsServers(clients []*Client, serverIDs []snowflake.ID) {
+	hub.notifyMu.Lock()
+	defer hub.notifyMu.Unlock()
+
+	for _, client := range clients {
+		select {
+		case <-client.done:
+			continue // unregistered meanwhile
+		default:
+		}
+
+		for _, serverID := range serverIDs {
+			if hub.serverClients[serverID] == nil {
+				hub.serverClients[serverID] = make(map[*Client]bool)
+			}
+			hub.serverClients[serverID][client] = true
+		}
+		client.servers = append(client.servers, serverIDs...)
+	}
+}
+
+// Remove the client from the notification indexes; client must be closed before
+func (hub *Hub) unindexClient(client *Client) {
+	hub.notifyMu.Lock()
+	defer hub.notifyMu.Unlock()
+
+	for _, serverID := range client.servers {
+		delete(hub.serverClients[serverID], client)
+		if len(hub.serverClients[serverID]) == 0 {
+			delete(hub.serverClients, serverID)
+		}
+	}
+	client.servers = nil
+
+	delete(hub.userClients[client.userID], client)
+	if len(hub.userClients[client.userID]) == 0 {
+		delete(hub.userClients, client.userID)
+	}
+}
+
+// Local clients of the user
+func (hub *Hub) userClientsSnapshot(userID UserID) []*Client {
+	hub.notifyMu.RLock()
+	defer hub.notifyMu.RUnlock()
+
+	clients := make([]*Client, 0, len(hub.userClients[userID]))
+	for client := range hub.userClients[userID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Notify the local clients of the server members; skip the clients matched by the skip
+func (hub *Hub) notifyServer(serverID snowflake.ID, event EventType, payload interface{}, skip func(*Client) bool) {
+	hub.notifyMu.RLock()
+	clients := make([]*Client, 0, len(hub.serverClients[serverID]))
+	for client := range hub.serverClients[serverID] {
+		if skip == nil || !skip(client) {
+			clients = append(clients, client)
+		}
+	}
+	hub.notifyMu.RUnlock()
+
+	hub.notifyClients(clients, event, payload)
+}
+
+// Notify every local session of the users
+func (hub *Hub) notifyUsers(userIDs []UserID, event EventType, payload interface{}) {
+	clients := make([]*Client, 0, len(userIDs))
+	for _, userID := range userIDs {
+		clients = append(clients, hub.userClientsSnapshot(userID)...)
+	}
+	hub.notifyClients(clients, event, payload)
+}
+
+// Send the notification to the clients; prepared once per codec, dropped for the full clients
+func (hub *Hub) notifyClients(clients []*Client, event EventType, payload interface{}) {
+	if len(clients) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	raw := FrameData(data)
+	frames := newPreparedFrames(&BroadcastRequest{Event: event, Data: &raw})
+
+	for _, client := range clients {
+		preparedMsg := frames.forClient(client)
+		if preparedMsg == nil {
+			continue
+		}
+		select {
+		case client.send <- preparedMsg:
+			// [METRIC]
+			hub.MetricNotification(event, true)
+		default:
+			// Lightweight event; client refetches the counts on resync
+			hub.MetricNotification(event, false)
+		}
+	}
+}
+
+// Server id of the server room; false for the other rooms
+func serverIDOfRoom(room string) (snowflake.ID, bool) {
+	roomParts := strings.SplitN(room, ":", 2)
+	if len(roomParts) != 2 || VIEW_TYPE(roomParts[0]) != SERVER_ROOM {
+		return 0, false
+	}
+	serverID, err := utils.ValidSnowflakeID(roomParts[1])
+	if err != nil {
+		return 0, false
+	}
+	return serverID, true
+}
+
+// Unread bump of the channel message for the server members outside the room
+func (hub *Hub) notifyChannelMessage(room string, data []byte) {
+	serverID, ok := serverIDOfRoom(room)
+	if !ok {
+		return
+	}
+
+	var message struct {
+		ID        snowflake.ID `json:"id"`
+		UserID    snowflake.ID `json:"userID"`
+		ChannelID snowflake.ID `json:"channelID"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return
+	}
+
+	unread := &UnreadNotification{
+		ServerID:  serverID,
+		ChannelID: message.ChannelID,
+		MessageID: message.ID,
+		AuthorID:  message.UserID,
+	}
+	// Room clients already get the message itself
+	hub.notifyServer(serverID, EventNotificationUnread, unread, func(client *Client) bool {
+		return client.userID == message.UserID || client.inRoom(room)
+	})
+}
+
+// Make handler for the channels CDC; channel created and deleted notifications
+func makeChannelsCDCHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var event baseDebezium.DebeziumEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, nil
+		}
+
+		var before, after channelDebezium
+		if len(event.Before) > 0 {
+			json.Unmarshal(event.Before, &before)
+		}
+		if len(event.After) > 0 {
+			json.Unmarshal(event.After, &after)
+		}
+
+		switch {
+		case event.Op == "c" && after.DeletedAt == nil:
+			hub.notifyServer(snowflake.ID(after.ServerID), EventNotificationChannelCreated, &ChannelNotification{
+				ServerID:  snowflake.ID(after.ServerID),
+				ChannelID: snowflake.ID(after.ID),
+				Name:      after.Name,
+				Type:      after.Type,
+			}, nil)
+
+		case event.Op == "u" && before.DeletedAt == nil && after.DeletedAt != nil: // soft deleted
+			hub.notifyServer(snowflake.ID(after.ServerID), EventNotificationChannelDeleted, &ChannelNotification{
+				ServerID:  snowflake.ID(after.ServerID),
+				ChannelID: snowflake.ID(after.ID),
+			}, nil)
+
+		case event.Op == "d" && before.DeletedAt == nil:
+			hub.notifyServer(snowflake.ID(before.ServerID), EventNotificationChannelDeleted, &ChannelNotification{
+				ServerID:  snowflake.ID(before.ServerID),
+				ChannelID: snowflake.ID(before.ID),
+			}, nil)
+		}
+		return nil, nil
+	}
+}
+
+// Make handler for the members CDC; member joined notification and the removed member room revocation
+func makeMembersCDCHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var event baseDebezium.DebeziumEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, nil
+		}
+
+		var before, after memberDebezium
+		if len(event.Before) > 0 {
+			json.Unmarshal(event.Before, &before)
+		}
+		if len(event.After) > 0 {
+			json.Unmarshal(event.After, &after)
+		}
+
+		// Role change or removal must not be served from the stale access cache
+		if event.Op == "u" || event.Op == "d" {
+			hub.invalidateServerMember(before, after)
+		}
+
+		switch {
+		case event.Op == "u" && before.DeletedAt == nil && after.DeletedAt != nil: // soft deleted; kicked or left
+			hub.revokeServerMember(snowflake.ID(after.UserID), snowflake.ID(after.ServerID))
+			return nil, nil
+
+		case event.Op == "d" && before.DeletedAt == nil:
+			hub.revokeServerMember(snowflake.ID(before.UserID), snowflake.ID(before.ServerID))
+			return nil, nil
+
+		case event.Op != "c" || len(event.After) == 0 || after.DeletedAt != nil:
+			return nil, nil
+		}
+
+		serverID := snowflake.ID(after.ServerID)
+		userID := snowflake.ID(after.UserID)
+
+		// New member sessions on this node start getting the server notifications
+		hub.addClientsServers(hub.userClientsSnapshot(userID), []snowflake.ID{serverID})
+
+		hub.notifyServer(serverID, EventNotificationMemberJoined, &MemberNotification{
+			ServerID: serverID,
+			UserID:   userID,
+			Role:     after.Role,
+		}, func(client *Client) bool {
+			return client.userID == userID
+		})
+		return nil, nil
+	}
+}
+
+// Make handler for the mention notifications; sent to the mentioned users whichever room they are in
+func makeMentionHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var notification mentionLib.Notification
+		if err := json.Unmarshal(msg.Value, &notification); err != nil {
+			return nil, nil
+		}
+
+		mention := &MentionNotification{
+			ServerID:  notification.ServerID,
+			ChannelID: notification.ChannelID,
+			MessageID: notification.MessageID,
+			AuthorID:  notification.AuthorID,
+			Roles:     notification.Roles,
+			Everyone:  notification.Everyone,
+		}
+		if notification.Everyone {
+			hub.notifyServer(notification.ServerID, EventNotificationMention, mention, func(client *Client) bool {
+				return client.userID == notification.AuthorID
+			})
+			return nil, nil
+		}
+
+		hub.notifyUsers(notification.UserIDs, EventNotificationMention, mention)
+		return nil, nil
+	}
+}
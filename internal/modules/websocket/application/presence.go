@@ -0,0 +1,133 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	presenceLib "github.com/himanshu3889/discore-backend/base/lib/presence"
+	serverStore "github.com/himanshu3889/discore-backend/base/store/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	presenceHeartbeatEvery = 3 // write pump pings; 30s with the ping interval
+	presenceIdleAfter      = 5 * time.Minute
+	presenceTimeout        = 2 * time.Second
+)
+
+// Presence update broadcast to the user server rooms
+type PresenceUpdate struct {
+	UserID UserID             `json:"userID"`
+	Status presenceLib.Status `json:"status"`
+}
+
+// Presence set by the client; e.g. app went to background
+type PresenceSetRequest struct {
+	Status presenceLib.Status `json:"status"`
+}
+
+// Mark the client active on incoming message
+func (client *Client) markActive() {
+	client.lastActiveAt.Store(time.Now().UnixMilli())
+}
+
+// Current presence of this connection
+func (client *Client) presenceStatus() presenceLib.Status {
+	lastActive := time.UnixMilli(client.lastActiveAt.Load())
+	if client.idle.Load() || time.Since(lastActive) > presenceIdleAfter {
+		return presenceLib.StatusIdle
+	}
+	return presenceLib.StatusOnline
+}
+
+// Heartbeat the client session presence; broadcast if user aggregated status changed.
+// Never runs after the disconnect so a late heartbeat can not bring the session back
+func (hub *Hub) presenceHeartbeat(client *Client) {
+	client.presenceMu.Lock()
+	defer client.presenceMu.Unlock()
+	if client.presenceGone {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, presenceTimeout)
+	defer cancel()
+
+	change, appErr := presenceLib.Heartbeat(ctx, client.userID, client.sessionID, client.presenceStatus())
+	if appErr != nil {
+		logrus.WithField("user_id", client.userID).Warnf("Presence heartbeat failed: %s", appErr.Message)
+		return
+	}
+	if change.Changed() {
+		hub.publishPresence(client.userID, change.Status)
+	}
+}
+
+// Remove the client session presence once disconnected
+func (hub *Hub) presenceDisconnect(client *Client) {
+	client.presenceMu.Lock()
+	defer client.presenceMu.Unlock()
+	client.presenceGone = true
+
+	ctx, cancel := context.WithTimeout(hub.ctx, presenceTimeout)
+	defer cancel()
+
+	change, appErr := presenceLib.RemoveSession(ctx, client.userID, client.sessionID)
+	if appErr != nil {
+		logrus.WithField("user_id", client.userID).Warnf("Presence remove failed: %s", appErr.Message)
+		return
+	}
+	if change.Changed() {
+		hub.publishPresence(client.userID, change.Status)
+	}
+}
+
+// Publish the presence update to every server room the user belongs to
+func (hub *Hub) publishPresence(userID UserID, status presenceLib.Status) {
+	ctx, cancel := context.WithTimeout(hub.ctx, presenceTimeout)
+	defer cancel()
+
+	servers, appErr := serverStore.UserJoinedServers(ctx, userID)
+	if appErr != nil {
+		return
+	}
+
+	data, err := json.Marshal(&PresenceUpdate{UserID: userID, Status: status})
+	if err != nil {
+		return
+	}
+
+	for _, server := range servers {
+		room := fmt.Sprintf("%s:%d", SERVER_ROOM, server.ID)
+		if err := hub.producer.Send(ctx, broadcastTopic(EventPresenceUpdate), room, data, userID); err != nil {
+			logrus.WithError(err).Error("Failed to forward presence to broadcast topic")
+			return
+		}
+	}
+}
+
+// Handle the presence set by the client
+func (hub *Hub) handlePresenceSet(client *Client, msg *SocketMessage) {
+	if msg.Data == nil {
+		return
+	}
+
+	var req PresenceSetRequest
+	if err := json.Unmarshal(*msg.Data, &req); err != nil {
+		return
+	}
+
+	switch req.Status {
+	case presenceLib.StatusIdle:
+		client.idle.Store(true)
+	case presenceLib.StatusOnline:
+		client.idle.Store(false)
+		client.markActive()
+	default:
+		return
+	}
+
+	go hub.presenceHeartbeat(client)
+}
@@ -1,21 +1,18 @@
 package websocketApp
 
 import (
-	"encoding/json"
-
 	"github.com/himanshu3889/discore-backend/configs"
 
 	"github.com/go-redis/redis_rate/v10"
-	"github.com/gorilla/websocket"
 )
 
 // Rate limit response structure sent to client
 type RateLimitError struct {
-	Event      string `json:"event"`       // "rate_limit"
-	Error      string `json:"error"`       // "Too many messages"
-	RetryAfter int    `json:"retry_after"` // seconds
-	Reset      int    `json:"reset"`
-	Limit      int    `json:"limit"`
+	Event      string `json:"event" msgpack:"event"`             // "rate_limit"
+	Error      string `json:"error" msgpack:"error"`             // "Too many messages"
+	RetryAfter int    `json:"retry_after" msgpack:"retry_after"` // seconds
+	Reset      int    `json:"reset" msgpack:"reset"`
+	Limit      int    `json:"limit" msgpack:"limit"`
 }
 
 // Ratelimiting: Returns true if allowed, false if blocked
@@ -40,8 +37,7 @@ func (hub *Hub) ApplyRateLimit(client *Client) bool {
 			Limit:      limit,
 		}
 
-		messageBytes, _ := json.Marshal(msg)
-		preparedMsg, err := websocket.NewPreparedMessage(websocket.TextMessage, messageBytes)
+		preparedMsg, err := client.codec.prepare(msg)
 		if err != nil {
 			return true
 		}
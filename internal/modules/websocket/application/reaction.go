@@ -0,0 +1,247 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	broadcastLib "github.com/himanshu3889/discore-backend/base/lib/broadcast"
+	channelMessageLib "github.com/himanshu3889/discore-backend/base/lib/channelMessage"
+	directMessageLib "github.com/himanshu3889/discore-backend/base/lib/directMessage"
+	reactionLib "github.com/himanshu3889/discore-backend/base/lib/reaction"
+	"github.com/himanshu3889/discore-backend/base/models"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Broadcast topic of the reaction changes; rest and socket changes of every node
+var reactionTopic = broadcastLib.Topic(reactionLib.Event)
+
+const (
+	flushReactionDelay = 500 * time.Millisecond
+	maxTrackedReactors = 4 // users listed per emoji update; count is always exact
+)
+
+// Reaction update of the message emoji since the last flush; added and removed list at most the tracked reactors,
+// the actor gets its own state in the reaction ack
+type ReactionUpdate struct {
+	MessageID snowflake.ID `json:"messageID"`
+	Emoji     string       `json:"emoji"`
+	Count     int64        `json:"count"`
+	Added     []UserID     `json:"added,omitempty"`
+	Removed   []UserID     `json:"removed,omitempty"`
+}
+
+// Ack of the reaction add or remove; reactions of the message with the me flags of the actor
+type ReactionAck struct {
+	RequestID string             `json:"requestID,omitempty"`
+	ID        snowflake.ID       `json:"id"`
+	Reactions []*models.Reaction `json:"reactions"`
+}
+
+type reactionKey struct {
+	messageID snowflake.ID
+	emoji     string
+}
+
+// Room reaction coalescer; latest count of each message emoji changed since the last flush
+type ReactionCoalescer struct {
+	updates map[reactionKey]*ReactionUpdate
+	timer   *time.Timer
+	mu      sync.Mutex
+}
+
+func newReactionCoalescer() ReactionCoalescer {
+	return ReactionCoalescer{
+		updates: make(map[reactionKey]*ReactionUpdate),
+	}
+}
+
+// Add the reaction change in the room state; flushed once per window so a busy message never delays it
+func (room *RoomState) AddReactionChange(change *reactionLib.Change) {
+	room.reactions.mu.Lock()
+	defer room.reactions.mu.Unlock()
+
+	key := reactionKey{messageID: change.MessageID, emoji: change.Emoji}
+	update, exists := room.reactions.updates[key]
+	if !exists {
+		update = &ReactionUpdate{MessageID: change.MessageID, Emoji: change.Emoji}
+		room.reactions.updates[key] = update
+	}
+	update.Count = change.Count
+
+	if change.Added {
+		update.Removed = withoutUser(update.Removed, change.UserID)
+		update.Added = withUser(update.Added, change.UserID)
+	} else {
+		update.Added = withoutUser(update.Added, change.UserID)
+		update.Removed = withUser(update.Removed, change.UserID)
+	}
+
+	if room.reactions.timer == nil {
+		room.reactions.timer = time.AfterFunc(flushReactionDelay, room.flushReactions)
+	}
+}
+
+// Flush the reaction updates of the room in a single event
+func (room *RoomState) flushReactions() {
+	pipelineStart := time.Now()
+
+	room.reactions.mu.Lock()
+	room.reactions.timer = nil
+	if len(room.reactions.updates) == 0 {
+		room.reactions.mu.Unlock()
+		return
+	}
+
+	// Load shedding; counts are the latest state so kept for the next window
+	if len(room.outBuffer) >= cap(room.outBuffer)*9/10 {
+		room.reactions.timer = time.AfterFunc(flushReactionDelay, room.flushReactions)
+		room.reactions.mu.Unlock()
+		return
+	}
+
+	updates := make([]*ReactionUpdate, 0, len(room.reactions.updates))
+	for _, update := range room.reactions.updates {
+		updates = append(updates, update)
+	}
+	room.reactions.updates = make(map[reactionKey]*ReactionUpdate)
+	room.reactions.mu.Unlock()
+
+	event := EventChannelMessageReactionUpdate
+	if strings.HasPrefix(room.name, fmt.Sprintf("%s:", DIRECT_ROOM)) {
+		event = EventDirectMessageReactionUpdate
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"reactions": updates,
+	})
+	if err != nil {
+		logrus.WithError(err).Errorf("Failed to marshal the reaction updates of room %s", room.name)
+		return
+	}
+	room.pushEvent(event, data, pipelineStart)
+}
+
+// Users with the user; at most the tracked reactors. Appended to a copy like the removal
+func withUser(userIDs []UserID, userID UserID) []UserID {
+	for _, id := range userIDs {
+		if id == userID {
+			return userIDs
+		}
+	}
+	if len(userIDs) == maxTrackedReactors {
+		return userIDs
+	}
+	return append(slices.Clip(userIDs), userID)
+}
+
+// Users without the user; a new slice so the pending update is never edited in place
+func withoutUser(userIDs []UserID, userID UserID) []UserID {
+	for i, id := range userIDs {
+		if id == userID {
+			return slices.Delete(slices.Clone(userIDs), i, i+1)
+		}
+	}
+	return userIDs
+}
+
+// Handle the channel message reaction add or remove
+func (hub *Hub) handleChannelMessageReaction(client *Client, msg *SocketMessage) {
+	add := msg.Event == EventChannelMessageReactionAdd
+	failedEvent := EventChannelMessageReactionRemoveFailed
+	if add {
+		failedEvent = EventChannelMessageReactionAddFailed
+	}
+
+	req, ok := hub.parseMessageAction(client, msg, failedEvent, channelOfAction)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	var result *reactionLib.Result
+	var appErr *appError.Error
+	if add {
+		result, appErr = channelMessageLib.AddReaction(ctx, client.userID, req.ChannelID, req.ID, req.Emoji)
+	} else {
+		result, appErr = channelMessageLib.RemoveReaction(ctx, client.userID, req.ChannelID, req.ID, req.Emoji)
+	}
+	hub.publishReaction(ctx, client, msg, EventChannelMessageReactionAck, failedEvent, req, result, appErr)
+}
+
+// Handle the direct message reaction add or remove
+func (hub *Hub) handleDirectMessageReaction(client *Client, msg *SocketMessage) {
+	add := msg.Event == EventDirectMessageReactionAdd
+	failedEvent := EventDirectMessageReactionRemoveFailed
+	if add {
+		failedEvent = EventDirectMessageReactionAddFailed
+	}
+
+	req, ok := hub.parseMessageAction(client, msg, failedEvent, conversationOfAction)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	var result *reactionLib.Result
+	var appErr *appError.Error
+	if add {
+		result, appErr = directMessageLib.AddReaction(ctx, client.userID, req.ConversationID, req.ID, req.Emoji)
+	} else {
+		result, appErr = directMessageLib.RemoveReaction(ctx, client.userID, req.ConversationID, req.ID, req.Emoji)
+	}
+	hub.publishReaction(ctx, client, msg, EventDirectMessageReactionAck, failedEvent, req, result, appErr)
+}
+
+// Publish the reaction change to the room and ack the actor; nacked with the failed event on the error
+func (hub *Hub) publishReaction(ctx context.Context, client *Client, msg *SocketMessage, ackEvent, failedEvent EventType, req *MessageActionRequest, result *reactionLib.Result, appErr *appError.Error) {
+	if appErr != nil {
+		client.sendMessageActionAck(failedEvent, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.ID,
+			Reason:    messageActionReason(appErr),
+			Message:   appErr.Message,
+		})
+		return
+	}
+	reactionLib.Publish(ctx, hub.producer, result)
+
+	data, err := json.Marshal(&ReactionAck{RequestID: msg.RequestID, ID: req.ID, Reactions: result.Reactions})
+	if err != nil {
+		return
+	}
+	client.sendEvent(ackEvent, msg.Room, data)
+}
+
+// Make handler for the reaction changes of every node; coalesced in the local room
+func makeReactionHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var change reactionLib.Change
+		if err := json.Unmarshal(msg.Value, &change); err != nil {
+			return nil, nil
+		}
+
+		hub.mu.RLock()
+		roomState, roomExists := hub.rooms[string(msg.Key)]
+		hub.mu.RUnlock()
+
+		if !roomExists {
+			return nil, nil
+		}
+
+		roomState.AddReactionChange(&change)
+		return nil, nil
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	deliveryLib "github.com/himanshu3889/discore-backend/base/lib/delivery"
+	modelsLib "github.com/himanshu3889/discore-backend/base/lib/models"
 	"github.com/himanshu3889/discore-backend/base/models"
 	"github.com/himanshu3889/discore-backend/base/utils"
 	directmessageService "github.com/himanshu3889/discore-backend/internal/modules/websocket/services/directMessage"
@@ -19,37 +21,116 @@ type EventType string
 
 const (
 	// Subscribe event
-	EventRoomJoin   EventType = "room.join"
-	EventRoomLeave  EventType = "room.leave"
-	EventRoomJoined EventType = "room.joined"
-	EventRoomTyping EventType = "room.typing"
+	EventRoomJoin        EventType = "room.join"
+	EventRoomLeave       EventType = "room.leave"
+	EventRoomJoined      EventType = "room.joined"
+	EventRoomJoinFailed  EventType = "room.join_failed"
+	EventRoomLeft        EventType = "room.left"
+	EventRoomLeaveFailed EventType = "room.leave_failed"
+	EventRoomTyping      EventType = "room.typing"
+	EventRoomTypingStop  EventType = "room.typing.stop"
+	EventRoomRevoked     EventType = "room.revoked" // evicted after the membership removed
+	// Session Event
+	EventSessionReady       EventType = "session.ready"
+	EventSessionResume      EventType = "session.resume"
+	EventSessionResumed     EventType = "session.resumed"
+	EventRoomResyncRequired EventType = "room.resync_required"
+	EventServerReconnect    EventType = "server.reconnect"
+	// Auth Event
+	EventAuthRefresh       EventType = "auth.refresh"
+	EventAuthRefreshed     EventType = "auth.refreshed"
+	EventAuthRefreshFailed EventType = "auth.refresh_failed"
+	EventAuthExpiring      EventType = "auth.expiring" // token expires soon; connection closed with 4001 at the expiry
+	// Presence Event
+	EventPresenceSet    EventType = "presence.set"
+	EventPresenceUpdate EventType = "presence.update"
+	// Message delivery Event; sending/sent/failed state of the sender
+	EventMessageAccepted  EventType = "message.accepted"
+	EventMessagePersisted EventType = "message.persisted"
+	EventMessageFailed    EventType = "message.failed"
+	// Notification Event; per-user stream whichever room is viewed
+	EventNotificationUnread         EventType = "notification.unread"
+	EventNotificationMention        EventType = "notification.mention"
+	EventNotificationChannelCreated EventType = "notification.channel_created"
+	EventNotificationChannelDeleted EventType = "notification.channel_deleted"
+	EventNotificationMemberJoined   EventType = "notification.member_joined"
+	// Read state Event; synced to every session of the user
+	EventReadStateAck       EventType = "read-state.ack"
+	EventReadStateAckFailed EventType = "read-state.ack_failed"
+	EventReadStateUpdate    EventType = "read-state.update"
 	// Channel Event
 	EventChannelMessageAdd    EventType = "channel-message.add"
+	EventChannelMessageAck    EventType = "channel-message.ack" // retry of the already accepted nonce
 	EventChannelMessageUpdate EventType = "channel-message.update"
 	EventChannelMessageDelete EventType = "channel-message.delete"
+
+	EventChannelMessageUpdateFailed EventType = "channel-message.update_failed"
+	EventChannelMessageDeleteFailed EventType = "channel-message.delete_failed"
+
+	EventChannelPinsUpdate EventType = "channel.pins.update"
+
+	EventChannelMessageReactionAdd          EventType = "channel-message.reaction.add"
+	EventChannelMessageReactionRemove       EventType = "channel-message.reaction.remove"
+	EventChannelMessageReactionAck          EventType = "channel-message.reaction.ack"    // reactions of the message for the actor
+	EventChannelMessageReactionUpdate       EventType = "channel-message.reaction.update" // coalesced counts of the room
+	EventChannelMessageReactionAddFailed    EventType = "channel-message.reaction.add_failed"
+	EventChannelMessageReactionRemoveFailed EventType = "channel-message.reaction.remove_failed"
 	// Conversation Event
 	EventDirectMessageAdd    EventType = "direct-message.add"
 	EventDirectMessageUpdate EventType = "direct-message.update"
 	EventDirectMessageDelete EventType = "direct-message.delete"
+
+	EventDirectMessageUpdateFailed EventType = "direct-message.update_failed"
+	EventDirectMessageDeleteFailed EventType = "direct-message.delete_failed"
+
+	EventConversationPinsUpdate EventType = "conversation.pins.update"
+
+	EventDirectMessageReactionAdd          EventType = "direct-message.reaction.add"
+	EventDirectMessageReactionRemove       EventType = "direct-message.reaction.remove"
+	EventDirectMessageReactionAck          EventType = "direct-message.reaction.ack"    // reactions of the message for the actor
+	EventDirectMessageReactionUpdate       EventType = "direct-message.reaction.update" // coalesced counts of the room
+	EventDirectMessageReactionAddFailed    EventType = "direct-message.reaction.add_failed"
+	EventDirectMessageReactionRemoveFailed EventType = "direct-message.reaction.remove_failed"
 )
 
 type SocketMessage struct {
-	Event         EventType        `json:"event"`
-	Room          string           `json:"room"`
-	Data          *json.RawMessage `json:"data"`
-	PipelineStart time.Time        `json:"-"`
+	Event         EventType  `json:"event" msgpack:"event"`
+	Room          string     `json:"room" msgpack:"room"`
+	Data          *FrameData `json:"data" msgpack:"data"`
+	RequestID     string     `json:"requestID,omitempty" msgpack:"requestID,omitempty"` // client correlation id; echoed in the acks
+	PipelineStart time.Time  `json:"-" msgpack:"-"`
 }
 
 // Handle the incoming message from the user
 func (hub *Hub) HandleIncomingMessage(client *Client, recMessage []byte) {
 	var msg SocketMessage
-	if err := json.Unmarshal(recMessage, &msg); err != nil {
+	if err := client.codec.unmarshal(recMessage, &msg); err != nil {
 		// logrus.WithError(err).Warn("Invalid message format")
 		return
 	}
 
 	// logrus.Infof("Received: %s", recMessage)
-	if msg.Room == "" {
+	// Presence is not room scoped
+	if msg.Event == EventPresenceSet {
+		hub.handlePresenceSet(client, &msg)
+		return
+	}
+	// Token refresh is connection scoped
+	if msg.Event == EventAuthRefresh {
+		hub.handleAuthRefresh(client, &msg)
+		return
+	}
+	// Read state is not room scoped; e.g. mark the other channel read
+	if msg.Event == EventReadStateAck {
+		hub.handleReadStateAck(client, &msg)
+		return
+	}
+	client.markActive()
+
+	// Subscription operations are always acknowledged; validated by the subscribe itself
+	isSubscription := msg.Event == EventRoomJoin || msg.Event == EventRoomLeave || msg.Event == EventSessionResume
+
+	if msg.Room == "" && !isSubscription {
 		logrus.Warn("Missing room in message")
 		return
 	}
@@ -57,19 +138,37 @@ func (hub *Hub) HandleIncomingMessage(client *Client, recMessage []byte) {
 	msg.PipelineStart = time.Now()
 
 	// If client does not join the room only allow to join room event
-	if client.room == "" && msg.Event != EventRoomJoin {
+	if !isSubscription && !client.inRoom(msg.Room) {
 		return
 	}
 
 	switch msg.Event {
 	case EventRoomJoin:
-		hub.handleRoomJoin(client, msg.Room)
+		hub.handleRoomJoin(client, msg.Room, msg.RequestID)
+	case EventRoomLeave:
+		hub.handleRoomLeave(client, msg.Room, msg.RequestID)
+	case EventSessionResume:
+		hub.handleSessionResume(client, &msg)
 	case EventRoomTyping:
 		hub.handleRoomTyping(client, msg.Room)
+	case EventRoomTypingStop:
+		hub.handleRoomTypingStop(client, msg.Room)
 	case EventChannelMessageAdd:
 		hub.handleChannelMessageAdd(client, &msg)
+	case EventChannelMessageUpdate:
+		hub.handleChannelMessageUpdate(client, &msg)
+	case EventChannelMessageDelete:
+		hub.handleChannelMessageDelete(client, &msg)
 	case EventDirectMessageAdd:
 		hub.handleDirectMessageAdd(client, &msg)
+	case EventDirectMessageUpdate:
+		hub.handleDirectMessageUpdate(client, &msg)
+	case EventDirectMessageDelete:
+		hub.handleDirectMessageDelete(client, &msg)
+	case EventChannelMessageReactionAdd, EventChannelMessageReactionRemove:
+		hub.handleChannelMessageReaction(client, &msg)
+	case EventDirectMessageReactionAdd, EventDirectMessageReactionRemove:
+		hub.handleDirectMessageReaction(client, &msg)
 	default:
 		logrus.Warnf("Unknown event '%s' from user %s", msg.Event, client.userID)
 	}
@@ -81,20 +180,25 @@ func _validateClientRoomMessage(client *Client, msg *SocketMessage) error {
 	if msg.Data == nil {
 		return fmt.Errorf("event %s: nil data", msg.Event)
 	}
-	if client.room != msg.Room {
+	if !client.inRoom(msg.Room) {
 		return fmt.Errorf("Invalid room `%s` message", msg.Room)
 	}
 	return nil
 }
 
 // Handle the room join; TODO: need timouts guard
-func (hub *Hub) handleRoomJoin(client *Client, room string) {
+func (hub *Hub) handleRoomJoin(client *Client, room string, requestID string) {
 	// Timeout pattern: Allow brief wait for subscribe
-	roomRequest := &RoomRequest{client: client, name: room}
-	hub.BuildRoomBroadcaster(room)
+	roomRequest := &RoomRequest{client: client, name: room, requestID: requestID}
 	hub.SubscribeRoom(roomRequest)
 }
 
+// Handle the room leave
+func (hub *Hub) handleRoomLeave(client *Client, room string, requestID string) {
+	roomRequest := &RoomRequest{client: client, name: room, requestID: requestID}
+	hub.UnsubscribeRoom(roomRequest)
+}
+
 // Handle the room typing
 func (hub *Hub) handleRoomTyping(client *Client, room string) {
 	hub.mu.RLock()
@@ -107,7 +211,18 @@ func (hub *Hub) handleRoomTyping(client *Client, room string) {
 
 	// [METRIC]
 	hub.MetricRoomTyping()
-	roomState.AddTyper(client.userID)
+
+	// Applied locally at once; other nodes through the broadcast topic
+	seq := time.Now().UnixNano()
+	if !roomState.AddTyper(client.userID, seq) {
+		return
+	}
+	go hub.publishTyping(room, &TypingSignal{UserID: client.userID, Typing: true, Seq: seq})
+}
+
+// Handle the room typing stop
+func (hub *Hub) handleRoomTypingStop(client *Client, room string) {
+	hub.stopTyping(client.userID, []string{room})
 }
 
 func (hub *Hub) handleChannelMessageAdd(client *Client, msg *SocketMessage) {
@@ -115,10 +230,73 @@ func (hub *Hub) handleChannelMessageAdd(client *Client, msg *SocketMessage) {
 		return
 	}
 
-	broadcastTopic := "broadcast." + msg.Event // FIXME: genererate it using func ?
+	var incomingMessage models.ChannelMessage
+	if err := json.Unmarshal(*msg.Data, &incomingMessage); err != nil {
+		logrus.WithError(err).Warn("Invalid message format")
+		client.sendMessageAck(EventMessageFailed, msg.Room, &MessageAck{
+			RequestID: msg.RequestID,
+			Reason:    deliveryLib.ReasonInvalidMessage,
+		})
+		return
+	}
+
+	// Channel must be of the room server; membership is checked by the room join
+	if reason := hub.roomChannelReason(msg.Room, incomingMessage.ServerID, incomingMessage.ChannelID); reason != "" {
+		client.sendMessageAck(EventMessageFailed, msg.Room, &MessageAck{
+			RequestID: msg.RequestID,
+			Reason:    reason,
+		})
+		return
+	}
+
+	// Reply carries its parent so clients render it without a fetch
+	incomingMessage.ReferencedMessage = nil
+	if incomingMessage.ReferencedMessageID != nil {
+		reference, reason := hub.messageReference(incomingMessage.ChannelID, *incomingMessage.ReferencedMessageID)
+		if reference == nil {
+			client.sendMessageAck(EventMessageFailed, msg.Room, &MessageAck{
+				RequestID: msg.RequestID,
+				Reason:    reason,
+			})
+			return
+		}
+		incomingMessage.ReferencedMessage = reference
+	}
+	modelsLib.SanitizeIncomingChannelMessage(&incomingMessage)
+
+	// Same content rule as the persistence; rejected before the nonce is claimed
+	content, appErr := modelsLib.ValidMessageContent(incomingMessage.Content)
+	if appErr != nil || (content == "" && incomingMessage.FileURL == nil) {
+		client.sendMessageAck(EventMessageFailed, msg.Room, &MessageAck{
+			RequestID: msg.RequestID,
+			Reason:    deliveryLib.ReasonInvalidMessage,
+		})
+		return
+	}
+	incomingMessage.Content = content
 
 	msgID := utils.GenerateSnowflakeID()
 
+	// Client retry of the same nonce gets the already assigned id
+	var messageNonce MessageNonce
+	json.Unmarshal(*msg.Data, &messageNonce)
+	nonce := messageNonce.key()
+	if nonce != "" {
+		assignedID, claimed := hub.claimMessageNonce(client.userID, nonce, msgID)
+		if !claimed {
+			client.sendMessageAck(EventChannelMessageAck, msg.Room, &MessageAck{
+				RequestID: msg.RequestID,
+				Nonce:     nonce,
+				ID:        assignedID,
+				Duplicate: true,
+			})
+			return
+		}
+	}
+
+	// Sent message ends the typing
+	hub.stopTyping(client.userID, []string{msg.Room})
+
 	ingestHeader := kafka.Header{
 		Key:   "ingest_time",
 		Value: []byte(fmt.Sprintf("%d", msg.PipelineStart.UnixMilli())),
@@ -129,44 +307,76 @@ func (hub *Hub) handleChannelMessageAdd(client *Client, msg *SocketMessage) {
 		Value: []byte(msgID.String()),
 	}
 
-	// Push in kafka to write to db
-	if err := hub.producer.Send(hub.ctx,
-		string(msg.Event),
-		msg.Room,
-		[]byte(*msg.Data),
-		client.userID,
-		traceHeader,
-		ingestHeader,
-	); err != nil {
-		logrus.WithError(err).Error("Kafka publish channel message failed")
-		return
-	}
+	ack := &MessageAck{RequestID: msg.RequestID, Nonce: nonce, ID: msgID}
 
-	var incomingMessage models.ChannelMessage
 	incomingMessage.ID = msgID
 	incomingMessage.UserID = client.userID
-
-	if err := json.Unmarshal(*msg.Data, &incomingMessage); err != nil {
-		logrus.WithError(err).Warn("Invalid message format")
-		return
-	}
+	incomingMessage.Nonce = nonce
 
 	createdMessageBytes, err := json.Marshal(incomingMessage)
 	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal channel message")
+		if nonce != "" {
+			hub.releaseMessageNonce(client.userID, nonce)
+		}
+		client.sendMessageAck(EventMessageFailed, msg.Room, &MessageAck{
+			RequestID: msg.RequestID,
+			Nonce:     nonce,
+			Reason:    deliveryLib.ReasonInvalidMessage,
+		})
 		return
 	}
 
-	// Push in kafka for broadcast
-	if err := hub.producer.Send(hub.ctx,
-		string(broadcastTopic),
+	// Peers only see the message once it reached the persistence topic
+	broadcast := func() {
+		if err := hub.producer.Send(hub.ctx,
+			broadcastTopic(msg.Event),
+			msg.Room,
+			createdMessageBytes,
+			client.userID,
+			traceHeader,
+			ingestHeader,
+			roomSeqKafkaHeader(hub.nextRoomSeq(msg.Room)),
+		); err != nil {
+			logrus.WithError(err).Error("Failed to forward to broadcast topic")
+		}
+	}
+
+	// Accepted once kafka has taken it; nonce released on failure so the client can retry
+	publishFailed := func(err error) {
+		logrus.WithError(err).Error("Kafka publish channel message failed")
+		if nonce != "" {
+			hub.releaseMessageNonce(client.userID, nonce)
+		}
+		client.sendMessageAck(EventMessageFailed, msg.Room, &MessageAck{
+			RequestID: ack.RequestID,
+			Nonce:     nonce,
+			ID:        msgID,
+			Reason:    deliveryLib.ReasonPublishFailed,
+		})
+	}
+	onDelivered := func(err error) {
+		if err != nil {
+			go publishFailed(err)
+			return
+		}
+		client.sendMessageAck(EventMessageAccepted, msg.Room, ack)
+		go broadcast()
+	}
+
+	// Push in kafka to write to db; session and nonce for the delivery status
+	if err := hub.producer.SendTracked(hub.ctx,
+		string(msg.Event),
 		msg.Room,
-		createdMessageBytes,
+		[]byte(*msg.Data),
 		client.userID,
+		onDelivered,
 		traceHeader,
 		ingestHeader,
+		kafka.Header{Key: deliveryLib.SessionHeader, Value: []byte(client.sessionID)},
+		kafka.Header{Key: deliveryLib.NonceHeader, Value: []byte(nonce)},
 	); err != nil {
-		logrus.WithError(err).Error("Failed to forward to broadcast topic")
-		return
+		publishFailed(err)
 	}
 }
 
@@ -175,38 +385,39 @@ func (hub *Hub) handleDirectMessageAdd(client *Client, msg *SocketMessage) {
 		return
 	}
 
-	directMsg, err := directmessageService.SendDirectMessage(msg.Data, client.userID)
+	// Sent message ends the typing
+	hub.stopTyping(client.userID, []string{msg.Room})
+
+	directMsg, err := directmessageService.SendDirectMessage((*json.RawMessage)(msg.Data), client.userID)
 	if err != nil {
 		return // Don't broadcast on error
 	}
 
-	byteMsg, err := json.Marshal(directMsg) // handle errors
-	if err != nil {
-		return
-	}
-	var raw json.RawMessage
-	err = json.Unmarshal(byteMsg, &raw)
+	createdMessageBytes, err := json.Marshal(directMsg)
 	if err != nil {
 		return
 	}
 
-	room := msg.Room
-
-	// CRITICAL: Check room exists BEFORE accessing
-	hub.mu.RLock()
-	roomState, roomExists := hub.rooms[room]
-	hub.mu.RUnlock()
-
-	if !roomExists {
-		// logrus.Warnf("Room %s not found, message dropped", room)
-		return
+	ingestHeader := kafka.Header{
+		Key:   "ingest_time",
+		Value: []byte(fmt.Sprintf("%d", msg.PipelineStart.UnixMilli())),
+	}
+	traceHeader := kafka.Header{
+		Key:   "trace_id",
+		Value: []byte(directMsg.ID.String()),
 	}
 
-	// Broadcast to room (fail-fast if room buffer full)
-	select {
-	case roomState.outBuffer <- &BroadcastRequest{Event: msg.Event, Room: msg.Room, Data: &raw, PipelineStart: msg.PipelineStart}:
-	// Success
-	default:
-		logrus.Warnf("Room buffer full, dropping message")
+	// Push in kafka for broadcast; every hub delivers to its local participants
+	if err := hub.producer.Send(hub.ctx,
+		broadcastTopic(msg.Event),
+		msg.Room,
+		createdMessageBytes,
+		client.userID,
+		traceHeader,
+		ingestHeader,
+		roomSeqKafkaHeader(hub.nextRoomSeq(msg.Room)),
+	); err != nil {
+		logrus.WithError(err).Error("Failed to forward direct message to broadcast topic")
+		return
 	}
 }
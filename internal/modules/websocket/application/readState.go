@@ -0,0 +1,79 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
+	readStateLib "github.com/himanshu3889/discore-backend/base/lib/readState"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Read up to the message of the channel or conversation
+type ReadStateAckRequest struct {
+	MessageID      snowflake.ID `json:"messageID"`
+	ChannelID      snowflake.ID `json:"channelID,omitempty"`
+	ConversationID snowflake.ID `json:"conversationID,omitempty"`
+}
+
+// Handle the read state ack; success is the read state update sent to every session of the user.
+// Ack already read past gets the current read state back on this session only
+func (hub *Hub) handleReadStateAck(client *Client, msg *SocketMessage) {
+	var req ReadStateAckRequest
+	if msg.Data == nil || json.Unmarshal(*msg.Data, &req) != nil || req.MessageID == 0 || (req.ChannelID == 0) == (req.ConversationID == 0) {
+		client.sendMessageActionAck(EventReadStateAckFailed, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.MessageID,
+			Reason:    MessageReasonInvalid,
+			Message:   "Message id and either its channel or conversation id are required",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, messageActionTimeout)
+	defer cancel()
+
+	var update *readStateLib.Update
+	var appErr *appError.Error
+	if req.ChannelID != 0 {
+		update, appErr = readStateLib.AckChannel(ctx, client.userID, req.ChannelID, req.MessageID)
+	} else {
+		update, appErr = readStateLib.AckConversation(ctx, client.userID, req.ConversationID, req.MessageID)
+	}
+	if appErr != nil {
+		client.sendMessageActionAck(EventReadStateAckFailed, msg.Room, &MessageActionAck{
+			RequestID: msg.RequestID,
+			ID:        req.MessageID,
+			Reason:    messageActionReason(appErr),
+			Message:   appErr.Message,
+		})
+		return
+	}
+
+	if !update.Changed {
+		data, err := json.Marshal(update)
+		if err == nil {
+			client.sendEvent(EventReadStateUpdate, msg.Room, data)
+		}
+		return
+	}
+	if err := readStateLib.Publish(ctx, hub.producer, update); err != nil {
+		logrus.WithError(err).Error("Failed to forward the read state to broadcast topic")
+	}
+}
+
+// Make handler for the read state changes; sent to every local session of the user
+func makeReadStateHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var update readStateLib.Update
+		if err := json.Unmarshal(msg.Value, &update); err != nil || update.UserID == 0 {
+			return nil, nil
+		}
+
+		hub.notifyUsers([]UserID{update.UserID}, EventReadStateUpdate, &update)
+		return nil, nil
+	}
+}
@@ -54,9 +54,10 @@ func (client *Client) ReadPump(hub *Hub) {
 }
 
 // writePump writes messages to the WebSocket connection.
-func (client *Client) WritePump() {
+func (client *Client) WritePump(hub *Hub) {
 	// TODO: remove from outside
 	ticker := time.NewTicker(pingInterval) // Ping interval
+	pings := 0
 	defer func() {
 		// logrus.Infof("Client disconnected by server: %s", client.conn.RemoteAddr())
 		ticker.Stop()
@@ -87,9 +88,46 @@ func (client *Client) WritePump() {
 				return
 			}
 
+			// Token must be refreshed before it expires
+			if !hub.checkClientToken(client) {
+				return
+			}
+
+			// Presence heartbeat tied to the pings
+			pings++
+			if pings%presenceHeartbeatEvery == 0 {
+				go hub.presenceHeartbeat(client)
+				go hub.connectionHeartbeat(client)
+			}
+
+		case <-client.drain: // Server shutdown; flush and close with the restart code
+			client.flushAndClose()
+			return
+
 		case <-client.done: // Listen for shutdown signal
 			// logrus.Warn("WritePump: shutdown signal received")
 			return
 		}
 	}
 }
+
+// Send a single event to the client only; drop if client buffer is full
+func (client *Client) sendEvent(event EventType, room string, data []byte) {
+	raw := FrameData(data)
+	var broadcastRequest = &BroadcastRequest{
+		Event: event,
+		Room:  room,
+		Data:  &raw,
+	}
+	preparedMsg, err := client.codec.prepare(broadcastRequest)
+	if err != nil {
+		return
+	}
+
+	select {
+	case client.send <- preparedMsg:
+		// Message queued for write pump
+	default:
+		// Send buffer full; slow client will be removed by the broadcaster
+	}
+}
@@ -0,0 +1,201 @@
+package websocketApp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	broadcastLib "github.com/himanshu3889/discore-backend/base/lib/broadcast"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	roomHistoryLen    = 256 // sequenced events kept per room for the replay
+	roomReorderWindow = 16  // seqs below the last seen one replayed again; concurrent publishes may arrive out of order
+	roomSeqTimeout    = 500 * time.Millisecond
+)
+
+// Bounded ring of the room sequenced events in the arrival order; guarded by the room lock.
+// Seqs are taken before the async kafka write, so events of the room may arrive out of the seq order
+type eventRing struct {
+	events []*BroadcastRequest
+	start  int // index of the oldest event
+	size   int
+	lost   bool // an event of the room came without its seq; only the clients that saw a later event are replayed
+}
+
+func newEventRing(capacity int) *eventRing {
+	return &eventRing{events: make([]*BroadcastRequest, capacity)}
+}
+
+// Push the event; overwrite the oldest once full
+func (ring *eventRing) push(event *BroadcastRequest) {
+	capacity := len(ring.events)
+	if ring.size < capacity {
+		ring.events[(ring.start+ring.size)%capacity] = event
+		ring.size++
+		return
+	}
+	ring.events[ring.start] = event
+	ring.start = (ring.start + 1) % capacity
+}
+
+// Drop the history; the event without seq can not be replayed, so the clients before it must resync
+func (ring *eventRing) markLost() {
+	clear(ring.events)
+	ring.start = 0
+	ring.size = 0
+	ring.lost = true
+}
+
+// Events after the lastSeq in the seq order; covered is false when the ring can not fill the gap.
+// Events within the reorder window below the lastSeq are replayed too as they may have arrived after it;
+// clients drop the seq already applied
+func (ring *eventRing) since(lastSeq uint64) (events []*BroadcastRequest, covered bool) {
+	if ring.size == 0 {
+		return nil, false
+	}
+
+	capacity := len(ring.events)
+	minSeq, maxSeq := ring.events[ring.start].Seq, uint64(0)
+	for i := 0; i < ring.size; i++ {
+		seq := ring.events[(ring.start+i)%capacity].Seq
+		minSeq = min(minSeq, seq)
+		maxSeq = max(maxSeq, seq)
+	}
+	if maxSeq < lastSeq {
+		return nil, false // Counter restarted after the room expired
+	}
+	if maxSeq == lastSeq {
+		return nil, true // Nothing missed
+	}
+	if minSeq > lastSeq+1 || (ring.lost && minSeq > lastSeq) {
+		return nil, false // Gap is older than the ring or crosses the lost event
+	}
+
+	fromSeq := uint64(0)
+	if lastSeq > roomReorderWindow {
+		fromSeq = lastSeq - roomReorderWindow
+	}
+	for i := 0; i < ring.size; i++ {
+		event := ring.events[(ring.start+i)%capacity]
+		if event.Seq > fromSeq {
+			events = append(events, event)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b *BroadcastRequest) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return events, true
+}
+
+// Next sequence of the room; same on every node as it lives in the redis
+func (hub *Hub) nextRoomSeq(room string) uint64 {
+	return broadcastLib.NextRoomSeq(hub.ctx, room)
+}
+
+// Current sequence of the room
+func (hub *Hub) currentRoomSeq(room string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(hub.ctx, roomSeqTimeout)
+	defer cancel()
+
+	seqKey, _ := rediskeys.Keys.Websocket.RoomSeq(room)
+	seq, err := redisDatabase.RedisClient.Get(ctx, seqKey).Uint64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// Kafka header carrying the room sequence
+func roomSeqKafkaHeader(seq uint64) kafka.Header {
+	return broadcastLib.RoomSeqKafkaHeader(seq)
+}
+
+// Parse the room sequence from kafka headers; false if unsequenced, 0 seq if the sequencing failed
+func parseRoomSeq(msg *kafka.Message) (uint64, bool) {
+	for _, h := range msg.Headers {
+		if h.Key == broadcastLib.RoomSeqHeader {
+			seq, _ := strconv.ParseUint(string(h.Value), 10, 64)
+			return seq, true
+		}
+	}
+	return 0, false
+}
+
+// Add the client in the room and replay the events after lastSeq before any live event.
+// Returns false when the gap can not be replayed from the room history.
+func (roomState *RoomState) addClientWithReplay(client *Client, roomName string, lastSeq uint64) bool {
+	roomState.mu.Lock()
+	defer roomState.mu.Unlock()
+
+	events, covered := roomState.history.since(lastSeq)
+
+	roomState.clients[client] = true
+	client.roomsMu.Lock()
+	client.rooms[roomName] = true
+	client.roomsMu.Unlock()
+
+	if len(events) == 0 {
+		return covered
+	}
+
+	// Queue while holding the room lock, so next live batch is always after the replay
+	preparedMsg, err := client.codec.prepare(events)
+	if err != nil {
+		return false
+	}
+
+	select {
+	case client.send <- preparedMsg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Has the room history lost an event
+func (roomState *RoomState) historyLost() bool {
+	roomState.mu.RLock()
+	defer roomState.mu.RUnlock()
+	return roomState.history.lost
+}
+
+// Subscribe the room on resume; ask client to resync if the gap can not be replayed
+func (hub *Hub) resumeRoom(client *Client, roomState *RoomState, roomName string, lastSeq uint64) {
+	if roomState.addClientWithReplay(client, roomName, lastSeq) {
+		hub.MetricSessionResume("replayed")
+		return
+	}
+
+	// Room history may be empty on this node; nothing missed if room has not moved.
+	// Not after a lost seq as the counter did not move for it
+	if roomState.historyLost() {
+		hub.MetricSessionResume("resync")
+		client.sendRoomResyncRequired(roomName, "replay_unavailable")
+		return
+	}
+	if currentSeq, err := hub.currentRoomSeq(roomName); err == nil && currentSeq == lastSeq {
+		hub.MetricSessionResume("replayed")
+		return
+	}
+
+	hub.MetricSessionResume("resync")
+	client.sendRoomResyncRequired(roomName, "replay_unavailable")
+}
+
+// Ask client to refetch the room state over the REST
+func (client *Client) sendRoomResyncRequired(room string, reason string) {
+	data, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		return
+	}
+	client.sendEvent(EventRoomResyncRequired, room, data)
+}
@@ -0,0 +1,245 @@
+package websocketApp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// Ring of the capacity with the seqs pushed in the arrival order; lostAfter marks the history lost after that many pushes
+func ringOf(capacity int, seqs []uint64, lostAfter int) *eventRing {
+	ring := newEventRing(capacity)
+	for i, seq := range seqs {
+		if i == lostAfter {
+			ring.markLost()
+		}
+		ring.push(&BroadcastRequest{Event: "test.event", Seq: seq})
+	}
+	return ring
+}
+
+func seqRange(from, to uint64) []uint64 {
+	var seqs []uint64
+	for seq := from; seq <= to; seq++ {
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+func seqsOf(events []*BroadcastRequest) []uint64 {
+	var seqs []uint64
+	for _, event := range events {
+		seqs = append(seqs, event.Seq)
+	}
+	return seqs
+}
+
+func TestEventRingSince(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    int
+		seqs        []uint64
+		lostAfter   int // -1 if never lost
+		lastSeq     uint64
+		wantSeqs    []uint64
+		wantCovered bool
+	}{
+		{
+			name:        "empty ring",
+			capacity:    8,
+			lostAfter:   -1,
+			lastSeq:     3,
+			wantCovered: false,
+		},
+		{
+			name:        "nothing missed",
+			capacity:    8,
+			seqs:        seqRange(1, 3),
+			lostAfter:   -1,
+			lastSeq:     3,
+			wantCovered: true,
+		},
+		{
+			name:        "client ahead of a restarted counter",
+			capacity:    8,
+			seqs:        seqRange(1, 2),
+			lostAfter:   -1,
+			lastSeq:     5,
+			wantCovered: false,
+		},
+		{
+			name:        "gap replayed with the whole reorder window",
+			capacity:    8,
+			seqs:        seqRange(1, 5),
+			lostAfter:   -1,
+			lastSeq:     2,
+			wantSeqs:    seqRange(1, 5),
+			wantCovered: true,
+		},
+		{
+			name:        "events below the reorder window left out",
+			capacity:    64,
+			seqs:        seqRange(1, 40),
+			lostAfter:   -1,
+			lastSeq:     30,
+			wantSeqs:    seqRange(30-roomReorderWindow+1, 40),
+			wantCovered: true,
+		},
+		{
+			name:        "out of order arrivals replayed in the seq order",
+			capacity:    8,
+			seqs:        []uint64{20, 22, 21, 24, 23},
+			lostAfter:   -1,
+			lastSeq:     21,
+			wantSeqs:    seqRange(20, 24),
+			wantCovered: true,
+		},
+		{
+			name:        "gap older than the ring",
+			capacity:    4,
+			seqs:        seqRange(1, 10),
+			lostAfter:   -1,
+			lastSeq:     5,
+			wantCovered: false,
+		},
+		{
+			name:        "gap right at the oldest event of the wrapped ring",
+			capacity:    4,
+			seqs:        seqRange(1, 10),
+			lostAfter:   -1,
+			lastSeq:     6,
+			wantSeqs:    seqRange(7, 10),
+			wantCovered: true,
+		},
+		{
+			name:        "gap across the lost event",
+			capacity:    8,
+			seqs:        []uint64{1, 2, 4, 5},
+			lostAfter:   2,
+			lastSeq:     3,
+			wantCovered: false,
+		},
+		{
+			name:        "client saw an event after the lost one",
+			capacity:    8,
+			seqs:        []uint64{1, 2, 4, 5},
+			lostAfter:   2,
+			lastSeq:     4,
+			wantSeqs:    []uint64{4, 5},
+			wantCovered: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := ringOf(tt.capacity, tt.seqs, tt.lostAfter)
+			events, covered := ring.since(tt.lastSeq)
+			if covered != tt.wantCovered {
+				t.Errorf("since(%d) covered = %v, want %v", tt.lastSeq, covered, tt.wantCovered)
+			}
+			if got := seqsOf(events); !reflect.DeepEqual(got, tt.wantSeqs) {
+				t.Errorf("since(%d) seqs = %v, want %v", tt.lastSeq, got, tt.wantSeqs)
+			}
+		})
+	}
+}
+
+// Client whose codec records the framed values instead of only encoding them
+func recordingClient(frames *[]interface{}) *Client {
+	return &Client{
+		send:  make(chan *websocket.PreparedMessage, 4),
+		rooms: make(map[string]bool),
+		codec: &WireCodec{
+			name:        SubprotocolJSON,
+			messageType: websocket.TextMessage,
+			marshal: func(v interface{}) ([]byte, error) {
+				*frames = append(*frames, v)
+				return json.Marshal(v)
+			},
+			unmarshal: json.Unmarshal,
+		},
+	}
+}
+
+func TestResumeRoom(t *testing.T) {
+	const roomName = "server:1"
+
+	tests := []struct {
+		name      string
+		seqs      []uint64
+		lostAfter int
+		lastSeq   uint64
+		wantSeqs  []uint64 // replayed seqs; nil if not replayed
+		wantEvent EventType
+	}{
+		{
+			name:      "missed events replayed",
+			seqs:      seqRange(1, 5),
+			lostAfter: -1,
+			lastSeq:   3,
+			wantSeqs:  seqRange(1, 5),
+		},
+		{
+			name:      "nothing missed",
+			seqs:      seqRange(1, 5),
+			lostAfter: -1,
+			lastSeq:   5,
+		},
+		{
+			name:      "resync across the lost event",
+			seqs:      []uint64{1, 2, 4, 5},
+			lostAfter: 2,
+			lastSeq:   2,
+			wantEvent: EventRoomResyncRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var frames []interface{}
+			client := recordingClient(&frames)
+			roomState := &RoomState{
+				name:    roomName,
+				clients: make(map[*Client]bool),
+				history: ringOf(roomHistoryLen, tt.seqs, tt.lostAfter),
+			}
+
+			(&Hub{}).resumeRoom(client, roomState, roomName, tt.lastSeq)
+
+			if !roomState.clients[client] || !client.inRoom(roomName) {
+				t.Fatalf("client not subscribed to the room")
+			}
+			if len(client.send) != len(frames) {
+				t.Fatalf("queued %d frames, recorded %d", len(client.send), len(frames))
+			}
+
+			switch {
+			case tt.wantSeqs != nil:
+				if len(frames) != 1 {
+					t.Fatalf("frames = %d, want the one replay batch", len(frames))
+				}
+				events, ok := frames[0].([]*BroadcastRequest)
+				if !ok {
+					t.Fatalf("frame = %T, want the replay batch", frames[0])
+				}
+				if got := seqsOf(events); !reflect.DeepEqual(got, tt.wantSeqs) {
+					t.Errorf("replayed seqs = %v, want %v", got, tt.wantSeqs)
+				}
+			case tt.wantEvent != "":
+				if len(frames) != 1 {
+					t.Fatalf("frames = %d, want the one %s event", len(frames), tt.wantEvent)
+				}
+				event, ok := frames[0].(*BroadcastRequest)
+				if !ok || event.Event != tt.wantEvent || event.Room != roomName {
+					t.Errorf("frame = %+v, want %s of room %s", frames[0], tt.wantEvent, roomName)
+				}
+			default:
+				if len(frames) != 0 {
+					t.Errorf("frames = %d, want none", len(frames))
+				}
+			}
+		})
+	}
+}
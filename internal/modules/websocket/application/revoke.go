@@ -0,0 +1,125 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	memberCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/member"
+	baseDebezium "github.com/himanshu3889/discore-backend/base/infrastructure/debezium"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/segmentio/kafka-go"
+)
+
+// CDC topic of the conversations; removed conversation revokes its direct room
+const cdcConversationsTopic = "postgres.public.conversations"
+
+// Reason codes of the revoked room
+const (
+	RoomReasonMembershipRevoked   RoomAckReason = "membership_revoked"
+	RoomReasonConversationRemoved RoomAckReason = "conversation_removed"
+)
+
+// Conversation row of the debezium event
+type conversationDebezium struct {
+	ID      int64 `json:"id"`
+	User1ID int64 `json:"user1_id"`
+	User2ID int64 `json:"user2_id"`
+}
+
+// Evict the local user clients from the room and tell them why; the other rooms stay subscribed
+func (hub *Hub) revokeRoom(userID UserID, room string, reason RoomAckReason, message string) {
+	hub.mu.RLock()
+	roomState := hub.rooms[room]
+	hub.mu.RUnlock()
+
+	for _, client := range hub.userClientsSnapshot(userID) {
+		if !client.inRoom(room) {
+			continue
+		}
+
+		if roomState != nil {
+			roomState.removeClient(client, room)
+		} else {
+			client.roomsMu.Lock()
+			delete(client.rooms, room)
+			client.roomsMu.Unlock()
+		}
+
+		client.sendRoomAck(EventRoomRevoked, room, &RoomAck{Reason: reason, Message: message})
+		// Resumed session must not rejoin the revoked room
+		hub.saveSession(client, sessionStateTTL)
+
+		// [METRIC]
+		hub.MetricRoomRevoked(reason)
+	}
+
+	hub.stopTyping(userID, []string{room})
+}
+
+// Remove the user clients from the server notification index
+func (hub *Hub) removeUserServer(userID UserID, serverID snowflake.ID) {
+	hub.notifyMu.Lock()
+	defer hub.notifyMu.Unlock()
+
+	for client := range hub.userClients[userID] {
+		delete(hub.serverClients[serverID], client)
+
+		servers := client.servers[:0]
+		for _, id := range client.servers {
+			if id != serverID {
+				servers = append(servers, id)
+			}
+		}
+		client.servers = servers
+	}
+	if len(hub.serverClients[serverID]) == 0 {
+		delete(hub.serverClients, serverID)
+	}
+}
+
+// Invalidate the cached member of the changed row; every hub does it, the first wins
+func (hub *Hub) invalidateServerMember(before, after memberDebezium) {
+	member := after
+	if member.UserID == 0 {
+		member = before
+	}
+	if member.UserID == 0 || member.ServerID == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(hub.ctx, SubscribeTimeout)
+	defer cancel()
+	memberCacheStore.InvalidateServerMember(ctx, snowflake.ID(member.UserID), snowflake.ID(member.ServerID))
+}
+
+// Revoke the server room of the removed member
+func (hub *Hub) revokeServerMember(userID UserID, serverID snowflake.ID) {
+	hub.removeUserServer(userID, serverID)
+	hub.revokeRoom(userID, fmt.Sprintf("%s:%d", SERVER_ROOM, serverID), RoomReasonMembershipRevoked, "No longer a member of the server")
+}
+
+// Make handler for the conversations CDC; removed conversation revokes the direct room of both users
+func makeConversationsCDCHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var event baseDebezium.DebeziumEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, nil
+		}
+		if event.Op != "d" || len(event.Before) == 0 {
+			return nil, nil
+		}
+
+		var conversation conversationDebezium
+		if err := json.Unmarshal(event.Before, &conversation); err != nil {
+			return nil, nil
+		}
+
+		room := fmt.Sprintf("%s:%d", DIRECT_ROOM, conversation.ID)
+		for _, userID := range []int64{conversation.User1ID, conversation.User2ID} {
+			hub.revokeRoom(snowflake.ID(userID), room, RoomReasonConversationRemoved, "Conversation was removed")
+		}
+		return nil, nil
+	}
+}
@@ -0,0 +1,152 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	redisDatabase "github.com/himanshu3889/discore-backend/base/infrastructure/redis"
+	rediskeys "github.com/himanshu3889/discore-backend/base/lib/redisKeys"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sessionStateTTL     = 24 * time.Hour  // while connection is alive
+	sessionResumeWindow = 5 * time.Minute // after connection dropped
+	sessionStoreTimeout = 500 * time.Millisecond
+)
+
+// Session state kept in redis so client can resume on any node
+type SessionState struct {
+	UserID UserID   `json:"userID"`
+	Rooms  []string `json:"rooms"`
+}
+
+// Session ready payload sent once connected
+type SessionReady struct {
+	SessionID     string `json:"sessionID"`
+	ResumeSeconds int    `json:"resumeSeconds"`
+}
+
+// Resume request from the reconnecting client
+type SessionResumeRequest struct {
+	SessionID string            `json:"sessionID"`
+	LastSeqs  map[string]uint64 `json:"lastSeqs"` // room -> last seen seq
+}
+
+// Resume ack
+type SessionResumed struct {
+	RequestID string   `json:"requestID,omitempty"`
+	SessionID string   `json:"sessionID"`
+	Rooms     []string `json:"rooms"`
+}
+
+func newSessionID() string {
+	return uuid.NewString()
+}
+
+// Hand out the session id to the connected client
+func (client *Client) sendSessionReady() {
+	data, err := json.Marshal(&SessionReady{
+		SessionID:     client.sessionID,
+		ResumeSeconds: int(sessionResumeWindow.Seconds()),
+	})
+	if err != nil {
+		return
+	}
+	client.sendEvent(EventSessionReady, "", data)
+}
+
+// Save the client session in the redis
+func (hub *Hub) saveSession(client *Client, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(hub.ctx, sessionStoreTimeout)
+	defer cancel()
+
+	state, err := json.Marshal(&SessionState{UserID: client.userID, Rooms: client.roomNames()})
+	if err != nil {
+		return
+	}
+
+	sessionKey, _ := rediskeys.Keys.Websocket.Session(client.sessionID)
+	if err := redisDatabase.RedisClient.Set(ctx, sessionKey, state, ttl).Err(); err != nil {
+		logrus.WithError(err).Warn("Failed to save websocket session")
+	}
+}
+
+// Load the session of the user; nil if expired or not owned by user
+func (hub *Hub) loadSession(sessionID string, userID UserID) *SessionState {
+	ctx, cancel := context.WithTimeout(hub.ctx, sessionStoreTimeout)
+	defer cancel()
+
+	sessionKey, _ := rediskeys.Keys.Websocket.Session(sessionID)
+	raw, err := redisDatabase.RedisClient.Get(ctx, sessionKey).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			logrus.WithError(err).Warn("Failed to load websocket session")
+		}
+		return nil
+	}
+
+	var state SessionState
+	if err := json.Unmarshal(raw, &state); err != nil || state.UserID != userID {
+		return nil
+	}
+	return &state
+}
+
+// Delete the session from the redis
+func (hub *Hub) deleteSession(sessionID string) {
+	ctx, cancel := context.WithTimeout(hub.ctx, sessionStoreTimeout)
+	defer cancel()
+
+	sessionKey, _ := rediskeys.Keys.Websocket.Session(sessionID)
+	redisDatabase.RedisClient.Del(ctx, sessionKey)
+}
+
+// Handle the session resume; restore the rooms and replay the missed events
+func (hub *Hub) handleSessionResume(client *Client, msg *SocketMessage) {
+	if msg.Data == nil {
+		return
+	}
+
+	var resumeReq SessionResumeRequest
+	if err := json.Unmarshal(*msg.Data, &resumeReq); err != nil || resumeReq.SessionID == "" {
+		client.sendRoomResyncRequired("", "invalid_resume")
+		return
+	}
+
+	state := hub.loadSession(resumeReq.SessionID, client.userID)
+	if state == nil {
+		// [METRIC]
+		hub.MetricSessionResume("expired")
+		client.sendRoomResyncRequired("", "session_expired")
+		return
+	}
+
+	// Connection keeps its new session; old one can not be resumed twice
+	hub.deleteSession(resumeReq.SessionID)
+
+	for _, room := range state.Rooms {
+		roomRequest := &RoomRequest{
+			client:    client,
+			name:      room,
+			requestID: msg.RequestID,
+			resume:    true,
+			lastSeq:   resumeReq.LastSeqs[room],
+		}
+		hub.SubscribeRoom(roomRequest)
+	}
+
+	data, err := json.Marshal(&SessionResumed{
+		RequestID: msg.RequestID,
+		SessionID: client.sessionID,
+		Rooms:     client.roomNames(),
+	})
+	if err != nil {
+		return
+	}
+	client.sendEvent(EventSessionResumed, "", data)
+}
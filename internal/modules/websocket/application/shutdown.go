@@ -0,0 +1,158 @@
+package websocketApp
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	reconnectMinDelay      = 1 * time.Second
+	reconnectMaxDelay      = 15 * time.Second // spread the reconnects so other nodes are not stampeded
+	consumerStopTimeout    = 10 * time.Second
+	drainPollInterval      = 50 * time.Millisecond
+	drainCloseReason       = "server restarting"
+	drainRetryAfterSeconds = "5"
+)
+
+// Reconnect hint sent to the clients before the node goes away
+type ServerReconnect struct {
+	ReconnectAfterMs int64  `json:"reconnectAfterMs"`
+	Reason           string `json:"reason"`
+}
+
+// Jittered reconnect delay of the client
+func reconnectDelay() time.Duration {
+	return reconnectMinDelay + rand.N(reconnectMaxDelay-reconnectMinDelay)
+}
+
+// Shutdown the global hub; drain the clients gracefully
+func ShutdownHub(ctx context.Context) error {
+	if globalHub == nil {
+		return nil
+	}
+	return globalHub.Shutdown(ctx)
+}
+
+// Let the write pump flush, ask the client to reconnect and close; safe to call many times
+func (client *Client) startDrain() {
+	client.drainOnce.Do(func() {
+		close(client.drain)
+	})
+}
+
+// Write the queued messages, the reconnect hint and the close frame; called by the write pump on drain.
+// Hint is written on the conn so a full send buffer can't drop it; one deadline bounds a stuck client
+func (client *Client) flushAndClose() {
+	client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	for len(client.send) > 0 {
+		if err := client.conn.WritePreparedMessage(<-client.send); err != nil {
+			return
+		}
+	}
+
+	data, err := json.Marshal(&ServerReconnect{
+		ReconnectAfterMs: reconnectDelay().Milliseconds(),
+		Reason:           drainCloseReason,
+	})
+	if err != nil {
+		return
+	}
+	raw := FrameData(data)
+	hint, err := client.codec.prepare(&BroadcastRequest{Event: EventServerReconnect, Data: &raw})
+	if err != nil {
+		return
+	}
+	if err := client.conn.WritePreparedMessage(hint); err != nil {
+		return
+	}
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, drainCloseReason)
+	client.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait))
+}
+
+// Snapshot of the registered clients
+func (hub *Hub) clientsSnapshot() []*Client {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	clients := make([]*Client, 0, len(hub.clients))
+	for client := range hub.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Wait for the wait group until the context is done
+func waitWithContext(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown the hub; stop upgrades, flush the rooms, ask the clients to reconnect and close them
+func (hub *Hub) Shutdown(ctx context.Context) error {
+	logrus.Infof("Draining websocket hub `%s`...", hub.nodeID)
+
+	// Stop accepting upgrades and room broadcasters
+	hub.mu.Lock()
+	alreadyDraining := hub.draining.Swap(true)
+	hub.mu.Unlock()
+	if alreadyDraining {
+		return nil
+	}
+
+	// No more broadcast events from the other nodes
+	hub.stopBroadcastConsumer(consumerStopTimeout)
+
+	// Flush the room out buffers into the clients
+	close(hub.drainRooms)
+	if err := waitWithContext(ctx, hub.broadcasters.Wait); err != nil {
+		logrus.WithError(err).Warn("Room broadcasters not flushed before the deadline")
+	}
+
+	// Write pumps flush, send the reconnect hint then close
+	for _, client := range hub.clientsSnapshot() {
+		client.startDrain()
+	}
+
+	// Wait for the clients to unregister; force close the remaining ones at deadline
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+waitClients:
+	for atomic.LoadInt32(&hub.totalClients) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logrus.Warnf("Force closing %d websocket clients", atomic.LoadInt32(&hub.totalClients))
+			for _, client := range hub.clientsSnapshot() {
+				client.close()
+			}
+			break waitClients
+		}
+	}
+
+	// Session and presence cleanup of the unregistered clients
+	err := waitWithContext(ctx, hub.wg.Wait)
+
+	hub.cancel()
+	if hub.producer != nil {
+		hub.producer.Close()
+	}
+
+	logrus.Infof("Websocket hub `%s` drained", hub.nodeID)
+	return err
+}
@@ -3,15 +3,16 @@ package websocketApp
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 
-	memberCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/member"
+	accessLib "github.com/himanshu3889/discore-backend/base/lib/access"
+	"github.com/himanshu3889/discore-backend/base/lib/appError"
 	directmessageStore "github.com/himanshu3889/discore-backend/base/store/directMessage"
 	"github.com/himanshu3889/discore-backend/base/utils"
 
 	"github.com/bwmarrin/snowflake"
-	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
 
@@ -30,15 +31,45 @@ var Allowed_Room_Types = map[VIEW_TYPE]bool{
 
 var SubscribeTimeout = 3 * time.Second
 
-// Subscribe the room
+// Reason codes of the failed room subscription operations
+type RoomAckReason string
+
+const (
+	RoomReasonInvalidRoom    RoomAckReason = "invalid_room"
+	RoomReasonTypeNotAllowed RoomAckReason = "room_type_not_allowed"
+	RoomReasonNotMember      RoomAckReason = "not_member"
+	RoomReasonLimitReached   RoomAckReason = "room_limit_reached"
+	RoomReasonNotSubscribed  RoomAckReason = "not_subscribed"
+	RoomReasonInternal       RoomAckReason = "internal_error"
+)
+
+// Acknowledgement of the room subscription operation
+type RoomAck struct {
+	RequestID string        `json:"requestID,omitempty"` // client correlation id
+	Success   bool          `json:"success"`
+	Reason    RoomAckReason `json:"reason,omitempty"`
+	Message   string        `json:"message"`
+}
+
+// Subscribe the room; client keeps its other subscribed rooms
 func (hub *Hub) SubscribeRoom(roomReq *RoomRequest) {
 	client := roomReq.client
 	newRoomName := roomReq.name
 
+	// Negative ack for the join
+	joinFailed := func(reason RoomAckReason, message string) {
+		client.sendRoomAck(EventRoomJoinFailed, newRoomName, &RoomAck{
+			RequestID: roomReq.requestID,
+			Reason:    reason,
+			Message:   message,
+		})
+	}
+
 	// --- Validation (outside lock) ---
 	newRoomParts := strings.SplitN(newRoomName, ":", 2)
 	if len(newRoomParts) != 2 {
 		logrus.Warnf("Invalid room name format: %s", newRoomName)
+		joinFailed(RoomReasonInvalidRoom, "Invalid room name format")
 		return
 	}
 
@@ -46,68 +77,105 @@ func (hub *Hub) SubscribeRoom(roomReq *RoomRequest) {
 	newRoomTypeID, err := utils.ValidSnowflakeID(newRoomParts[1])
 	if err != nil {
 		logrus.Warnf("Invalid room")
+		joinFailed(RoomReasonInvalidRoom, "Invalid room id")
 		return
 	}
 
 	allowed, ok := Allowed_Room_Types[newRoomType]
 	if !ok || !allowed {
 		logrus.Warnf("Room type not allowed: %s", newRoomType)
+		joinFailed(RoomReasonTypeNotAllowed, "Room type not allowed")
 		return
 	}
 
-	oldRoomName := client.room
-	if oldRoomName == newRoomName {
-		return // Already in target room
-	}
-
-	// --- Get room states (hub lock only for map ops) ---
-	hub.mu.Lock()
-	var oldRoom *RoomState
-	if oldRoomName != "" {
-		oldRoom = hub.rooms[oldRoomName]
-	}
+	joined := &RoomAck{RequestID: roomReq.requestID, Success: true, Message: "Successfully joined room"}
 
-	newRoom := hub.rooms[newRoomName]
-	if newRoom == nil {
-		hub.GetOrCreateRoom(newRoomName)
+	if client.inRoom(newRoomName) {
+		client.sendRoomAck(EventRoomJoined, newRoomName, joined) // Already in target room
+		return
 	}
-	hub.mu.Unlock()
 
-	// --- Switch rooms (per-room locks only) ---
-	// Remove old room
-	if oldRoom != nil {
-		oldRoom.RemoveClients([]*Client{client})
+	if len(client.roomNames()) >= maxClientRooms {
+		logrus.Warnf("Client of user %s reached the max %d rooms", client.userID, maxClientRooms)
+		joinFailed(RoomReasonLimitReached, fmt.Sprintf("Max %d rooms per connection", maxClientRooms))
+		return
 	}
 
-	// Lock new room and add client
-
 	var canJoin bool
+	var appErr *appError.Error
 	switch newRoomType {
 	case SERVER_ROOM:
-		canJoin = newRoom.canClientInServerRoom(hub.ctx, client.userID, newRoomTypeID)
+		canJoin, appErr = canClientInServerRoom(hub.ctx, client.userID, newRoomTypeID)
 	case DIRECT_ROOM:
-		canJoin = newRoom.canClientInDirectRoom(hub.ctx, client.userID, newRoomTypeID)
+		canJoin, appErr = canClientInDirectRoom(hub.ctx, client.userID, newRoomTypeID)
+	}
+
+	if appErr != nil {
+		joinFailed(RoomReasonInternal, "Unable to verify room access")
+		return
 	}
 
 	if !canJoin {
+		joinFailed(RoomReasonNotMember, "Not a member of the room")
+		return
+	}
+
+	// --- Get room state along with broadcaster (hub lock only for map ops) ---
+	newRoom := hub.BuildRoomBroadcaster(newRoomName)
+
+	// Lock new room and add client
+	if roomReq.resume {
+		hub.resumeRoom(client, newRoom, newRoomName, roomReq.lastSeq)
+	} else {
+		newRoom.addClient(client, newRoomName)
+	}
+	client.sendRoomAck(EventRoomJoined, newRoomName, joined)
+	hub.saveSession(client, sessionStateTTL)
+
+}
+
+// Unsubscribe the room; connection stays alive for the other rooms
+func (hub *Hub) UnsubscribeRoom(roomReq *RoomRequest) {
+	client := roomReq.client
+	roomName := roomReq.name
+
+	if !client.inRoom(roomName) {
+		client.sendRoomAck(EventRoomLeaveFailed, roomName, &RoomAck{
+			RequestID: roomReq.requestID,
+			Reason:    RoomReasonNotSubscribed,
+			Message:   "Room is not subscribed",
+		})
 		return
 	}
 
-	newRoom.addClient(client, newRoomName)
-	client.sendSubscribeConfirmation()
+	hub.mu.RLock()
+	roomState := hub.rooms[roomName]
+	hub.mu.RUnlock()
+
+	if roomState != nil {
+		roomState.removeClient(client, roomName)
+	} else {
+		client.roomsMu.Lock()
+		delete(client.rooms, roomName)
+		client.roomsMu.Unlock()
+	}
 
+	client.sendRoomAck(EventRoomLeft, roomName, &RoomAck{
+		RequestID: roomReq.requestID,
+		Success:   true,
+		Message:   "Successfully left room",
+	})
+	hub.saveSession(client, sessionStateTTL)
 }
 
 // Check client user join the server room
-func (room *RoomState) canClientInServerRoom(ctx context.Context, userID snowflake.ID, serverID snowflake.ID) bool {
-	canJoin, _ := memberCacheStore.HasUserServerMember(ctx, userID, serverID)
-	return canJoin
+func canClientInServerRoom(ctx context.Context, userID snowflake.ID, serverID snowflake.ID) (bool, *appError.Error) {
+	return accessLib.CanReadServer(ctx, userID, serverID)
 }
 
 // Can client user join the dm room
-func (room *RoomState) canClientInDirectRoom(ctx context.Context, userID snowflake.ID, conversationID snowflake.ID) bool {
-	canJoin, _ := directmessageStore.HasValidConversationForUser(ctx, conversationID, userID)
-	return canJoin
+func canClientInDirectRoom(ctx context.Context, userID snowflake.ID, conversationID snowflake.ID) (bool, *appError.Error) {
+	return directmessageStore.HasValidConversationForUser(ctx, conversationID, userID)
 }
 
 // Add client in the room safely
@@ -115,24 +183,28 @@ func (roomState *RoomState) addClient(client *Client, roomName string) {
 	roomState.mu.Lock()
 	defer roomState.mu.Unlock()
 	roomState.clients[client] = true
-	client.room = roomName
+
+	client.roomsMu.Lock()
+	client.rooms[roomName] = true
+	client.roomsMu.Unlock()
 }
 
-// Subscribe confirmation once user join the room
-func (client *Client) sendSubscribeConfirmation() {
-	data := json.RawMessage(`{"success": true, "message": "Successfully joined room"}`)
-	var broadcastRequest = &BroadcastRequest{
-		Event: EventRoomJoined,
-		Room:  client.room,
-		Data:  &data,
-	}
-	messageBytes, err := json.Marshal(broadcastRequest)
-	if err != nil {
-		return
-	}
-	preparedMsg, err := websocket.NewPreparedMessage(websocket.TextMessage, messageBytes)
+// Remove client from the room safely without closing its connection
+func (roomState *RoomState) removeClient(client *Client, roomName string) {
+	roomState.mu.Lock()
+	defer roomState.mu.Unlock()
+	delete(roomState.clients, client)
+
+	client.roomsMu.Lock()
+	delete(client.rooms, roomName)
+	client.roomsMu.Unlock()
+}
+
+// Send the room subscription ack to the client
+func (client *Client) sendRoomAck(event EventType, room string, ack *RoomAck) {
+	data, err := json.Marshal(ack)
 	if err != nil {
 		return
 	}
-	client.send <- preparedMsg
+	client.sendEvent(event, room, data)
 }
@@ -1,6 +1,7 @@
 package websocketApp
 
 import (
+	"context"
 	"encoding/json"
 	"sync"
 	"time"
@@ -8,7 +9,8 @@ import (
 	userCacheStore "github.com/himanshu3889/discore-backend/base/cacheStore/user"
 
 	"github.com/bwmarrin/snowflake"
-	"golang.org/x/net/context"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
 )
 
 // Typer state that need to be broadcast
@@ -17,69 +19,189 @@ type Typer struct {
 	Name string `json:"name"`
 }
 
-// Room typing coalescer
+// Typer which stopped typing; explicit stop or expired
+type TypingStopped struct {
+	ID UserID `json:"id"`
+}
+
+// Typing signal shared by every node through the broadcast topic
+type TypingSignal struct {
+	UserID UserID `json:"userID"`
+	Typing bool   `json:"typing"` // false for the stop
+	Seq    int64  `json:"seq"`    // unix nanos at the origin; older signal of the user is ignored
+}
+
+// Typing state of the user in the room
+type typerState struct {
+	expiresAt   time.Time
+	publishedAt time.Time // last typing published for the other nodes
+}
+
+// Room typing coalescer; per-user typing state with the expiry
 type TypingCoalescer struct {
-	typers map[UserID]bool
-	total  int
-	timer  *time.Timer
-	mu     sync.Mutex
+	typers  map[UserID]*typerState
+	signals map[UserID]int64 // last applied signal seq of the user
+	stopped map[UserID]bool  // stopped since the last flush
+	changed bool             // typers changed since the last flush
+	timer   *time.Timer
+	mu      sync.Mutex
 }
 
 const (
-	flushTypingDelay = 500 * time.Millisecond
-	broadcastTimeout = 350 * time.Millisecond
-	maxTrackedTypers = 4
+	flushTypingDelay   = 500 * time.Millisecond
+	broadcastTimeout   = 350 * time.Millisecond
+	maxTrackedTypers   = 4
+	typingTTL          = 10 * time.Second
+	typingRefreshAfter = 5 * time.Second // republish the typing of an already typing user after this
+	typingTimeout      = 2 * time.Second
 )
 
-// Add the user in the room typing state
-func (room *RoomState) AddTyper(userID UserID) {
+func newTypingCoalescer() TypingCoalescer {
+	return TypingCoalescer{
+		typers:  make(map[UserID]*typerState),
+		signals: make(map[UserID]int64),
+		stopped: make(map[UserID]bool),
+	}
+}
+
+// Add the user in the room typing state; returns false if the typing need not be published again.
+// Signal older than the last applied one of the user is ignored
+func (room *RoomState) AddTyper(userID UserID, seq int64) bool {
 	room.typing.mu.Lock()
 	defer room.typing.mu.Unlock()
 
-	if _, exists := room.typing.typers[userID]; exists {
-		return
+	now := time.Now()
+	if !room.applySignal(userID, seq, now) {
+		return false
+	}
+
+	typer, exists := room.typing.typers[userID]
+	delete(room.typing.stopped, userID)
+
+	if exists {
+		typer.expiresAt = now.Add(typingTTL)
+		// Other nodes expire the typer after the ttl; republished while the user keeps typing
+		if now.Sub(typer.publishedAt) < typingRefreshAfter {
+			return false
+		}
+		typer.publishedAt = now
+		return true
+	}
+
+	room.typing.typers[userID] = &typerState{expiresAt: now.Add(typingTTL), publishedAt: now}
+	room.typing.changed = true
+	room.scheduleTypingFlush(flushTypingDelay)
+	return true
+}
+
+// Remove the user from the room typing state; returns false if the user was not typing.
+// Signal older than the last applied one of the user is ignored
+func (room *RoomState) RemoveTyper(userID UserID, seq int64) bool {
+	room.typing.mu.Lock()
+	defer room.typing.mu.Unlock()
+
+	if !room.applySignal(userID, seq, time.Now()) {
+		return false
+	}
+	if _, exists := room.typing.typers[userID]; !exists {
+		return false
 	}
 
-	if len(room.typing.typers) < maxTrackedTypers {
-		room.typing.typers[userID] = true
+	delete(room.typing.typers, userID)
+	room.typing.stopped[userID] = true
+	room.typing.changed = true
+	room.scheduleTypingFlush(flushTypingDelay)
+	return true
+}
+
+// Record the signal seq of the user; false if it is older than the applied one or expired. Take typing lock before using this
+func (room *RoomState) applySignal(userID UserID, seq int64, now time.Time) bool {
+	if seq <= room.typing.signals[userID] || seq < now.Add(-typingTTL).UnixNano() {
+		return false
 	}
-	room.typing.total++
+	room.typing.signals[userID] = seq
+	return true
+}
 
+// Schedule the typing flush; take typing lock before using this
+func (room *RoomState) scheduleTypingFlush(delay time.Duration) {
 	if room.typing.timer != nil {
 		room.typing.timer.Stop()
 	}
-	room.typing.timer = time.AfterFunc(flushTypingDelay, room.flushTyping)
+	room.typing.timer = time.AfterFunc(delay, room.flushTyping)
 }
 
-// Flush the typing in the room
+// Flush the typing in the room; typing of the current typers and stop of the stopped ones
 func (room *RoomState) flushTyping() {
-	// Load shedding
-	// Check if room's outBuffer is nearly full
-	if len(room.outBuffer) >= cap(room.outBuffer)*9/10 {
-		return
-	}
-
 	pipelineStart := time.Now()
 
 	// Grab data quickly, release lock
 	room.typing.mu.Lock()
-	typerIDs := make([]snowflake.ID, 0, len(room.typing.typers))
+	room.typing.timer = nil
+
+	// Expire the typers; earliest remaining expiry schedules the next flush
+	var nextExpiry time.Time
+	for id, typer := range room.typing.typers {
+		if !typer.expiresAt.After(pipelineStart) {
+			delete(room.typing.typers, id)
+			room.typing.stopped[id] = true
+			room.typing.changed = true
+			continue
+		}
+		if nextExpiry.IsZero() || typer.expiresAt.Before(nextExpiry) {
+			nextExpiry = typer.expiresAt
+		}
+	}
+	// Signals older than the ttl are rejected anyway; their seq need not be kept
+	expiredSeq := pipelineStart.Add(-typingTTL).UnixNano()
+	for id, seq := range room.typing.signals {
+		if _, typing := room.typing.typers[id]; !typing && seq < expiredSeq {
+			delete(room.typing.signals, id)
+		}
+	}
+	if !nextExpiry.IsZero() {
+		room.scheduleTypingFlush(nextExpiry.Sub(pipelineStart))
+	}
+
+	if !room.typing.changed {
+		room.typing.mu.Unlock()
+		return
+	}
+
+	total := len(room.typing.typers)
+	typerIDs := make([]snowflake.ID, 0, min(total, maxTrackedTypers))
 	for id := range room.typing.typers {
+		if len(typerIDs) == maxTrackedTypers {
+			break
+		}
 		typerIDs = append(typerIDs, id)
 	}
-	total := room.typing.total
-	room.typing.typers = make(map[UserID]bool)
-	room.typing.total = 0
-	room.typing.timer = nil
+	stoppedIDs := make([]UserID, 0, len(room.typing.stopped))
+	for id := range room.typing.stopped {
+		stoppedIDs = append(stoppedIDs, id)
+	}
+	room.typing.stopped = make(map[UserID]bool)
+	room.typing.changed = false
 	room.typing.mu.Unlock()
 
+	// Stops are sent before the typers; so client ends with the current typers
+	for _, id := range stoppedIDs {
+		data, _ := json.Marshal(&TypingStopped{ID: id})
+		room.pushEvent(EventRoomTypingStop, data, pipelineStart)
+	}
+
 	if total == 0 {
 		return
 	}
 
+	// Load shedding
+	// Check if room's outBuffer is nearly full; next change or expiry sends the current typers again
+	if len(room.outBuffer) >= cap(room.outBuffer)*9/10 {
+		return
+	}
+
 	// Now do work without lock
-	ctx := context.Background()
-	usersMap, err := userCacheStore.GetUsersBatch(ctx, typerIDs)
+	usersMap, err := userCacheStore.GetUsersBatch(room.ctx, typerIDs)
 	if err != nil {
 		return
 	}
@@ -97,10 +219,15 @@ func (room *RoomState) flushTyping() {
 		"users": typersList,
 		"total": total,
 	})
-	raw := json.RawMessage(data)
+	room.pushEvent(EventRoomTyping, data, pipelineStart)
+}
+
+// Push the unsequenced event in the room out buffer; e.g. typing and the reaction counts
+func (room *RoomState) pushEvent(event EventType, data []byte, pipelineStart time.Time) {
+	raw := FrameData(data)
 
 	req := &BroadcastRequest{
-		Event:         EventRoomTyping,
+		Event:         event,
 		Room:          room.name,
 		Data:          &raw,
 		PipelineStart: pipelineStart,
@@ -114,3 +241,59 @@ func (room *RoomState) flushTyping() {
 		// dropped
 	}
 }
+
+// Publish the user typing signal of the room for every node
+func (hub *Hub) publishTyping(room string, signal *TypingSignal) {
+	ctx, cancel := context.WithTimeout(hub.ctx, typingTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(signal)
+	if err != nil {
+		return
+	}
+
+	// Async publishes may reach kafka out of order; receivers drop the older seq of the user
+	if err := hub.producer.Send(ctx, broadcastTopic(EventRoomTyping), room, data, signal.UserID); err != nil {
+		logrus.WithError(err).Error("Failed to forward typing to broadcast topic")
+	}
+}
+
+// Stop the user typing in the rooms; e.g. message sent or disconnected. Publish is async
+func (hub *Hub) stopTyping(userID UserID, rooms []string) {
+	for _, room := range rooms {
+		hub.mu.RLock()
+		roomState, roomExists := hub.rooms[room]
+		hub.mu.RUnlock()
+
+		seq := time.Now().UnixNano()
+		if roomExists && roomState.RemoveTyper(userID, seq) {
+			go hub.publishTyping(room, &TypingSignal{UserID: userID, Typing: false, Seq: seq})
+		}
+	}
+}
+
+// Make handler for the typing signals of the every node
+func makeRoomTypingHandler(hub *Hub) func(*kafka.Message) (error, *kafka.Message) {
+	return func(msg *kafka.Message) (error, *kafka.Message) {
+		var signal TypingSignal
+		if err := json.Unmarshal(msg.Value, &signal); err != nil {
+			return nil, nil
+		}
+
+		hub.mu.RLock()
+		roomState, roomExists := hub.rooms[string(msg.Key)]
+		hub.mu.RUnlock()
+
+		if !roomExists {
+			return nil, nil
+		}
+
+		// Own signals are already applied; same seq is ignored
+		if signal.Typing {
+			roomState.AddTyper(signal.UserID, signal.Seq)
+		} else {
+			roomState.RemoveTyper(signal.UserID, signal.Seq)
+		}
+		return nil, nil
+	}
+}
@@ -1,10 +1,13 @@
 package websocketApp
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/himanshu3889/discore-backend/base/utils"
 	"github.com/himanshu3889/discore-backend/internal/modules/websocket/middlewares"
 	"github.com/sirupsen/logrus"
 )
@@ -13,11 +16,7 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins for simplicity in this example.
-		// In production, restrict this to your domain.
-		return true
-	},
+	CheckOrigin:     checkOrigin, // WS_ALLOWED_ORIGINS allow-list
 }
 
 // handles WebSocket requests for connections.
@@ -27,21 +26,60 @@ func WsHandler(ctx *gin.Context) {
 		logrus.Error("Invalid userID")
 		return
 	}
-	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	// Node is draining; client should retry on other node
+	if globalHub.draining.Load() {
+		ctx.Header("Retry-After", drainRetryAfterSeconds)
+		utils.RespondWithError(ctx, http.StatusServiceUnavailable, "Server is restarting")
+		return
+	}
+
+	// Node is at capacity; client should retry on other node
+	if !globalHub.admitNode() {
+		ctx.Header("Retry-After", nodeFullRetryAfterSeconds)
+		utils.RespondWithError(ctx, http.StatusServiceUnavailable, "Server is at capacity")
+		return
+	}
+
+	connectionID := uuid.NewString()
+	if !globalHub.acquireConnection(userID, connectionID) {
+		utils.RespondWithError(ctx, http.StatusTooManyRequests, fmt.Sprintf("Max %d connections per user", maxUserConnections()))
+		return
+	}
+
+	// Subprotocol in the server preference order; the upgrader alone picks by the client order
+	var responseHeader http.Header
+	if subprotocol := selectSubprotocol(websocket.Subprotocols(ctx.Request)); subprotocol != "" {
+		responseHeader = http.Header{"Sec-Websocket-Protocol": {subprotocol}}
+	}
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, responseHeader)
 	if err != nil {
 		logrus.WithError(err).Error("Upgrade error")
+		globalHub.releaseConnection(userID, connectionID)
 		return
 	}
 
 	conn.SetReadLimit(1024 * 1024) // Add this: reject messages > 1024 KB
 
-	client := &Client{conn: conn, send: make(chan *websocket.PreparedMessage, clientBufferSize), userID: userID, done: make(chan struct{})}
+	client := newClient(conn, userID)
+	client.connectionID = connectionID
+	client.setToken(middlewares.GetWsContextToken(ctx))
 
-	globalHub.register <- client // Register the new client
+	// Register the new client
+	select {
+	case globalHub.register <- client:
+	case <-globalHub.ctx.Done():
+		client.close()
+		globalHub.releaseConnection(userID, connectionID)
+		return
+	}
+	client.sendSessionReady()
 
-	go client.WritePump()      // Client's write goroutine
-	client.ReadPump(globalHub) // Client's read goroutine (blocks)
+	go client.WritePump(globalHub) // Client's write goroutine
+	client.ReadPump(globalHub)     // Client's read goroutine (blocks)
 
 	// Blocked by client readpump, When readPump exits, unregister the client
-	globalHub.unregister <- client
+	select {
+	case globalHub.unregister <- client:
+	case <-globalHub.ctx.Done(): // hub already shut down
+	}
 }
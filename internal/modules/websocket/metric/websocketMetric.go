@@ -29,6 +29,12 @@ var (
 		Help: "Total number of room subscription attempts that timed out",
 	})
 
+	// Broadcast consumer groups owned by this node. Labels: "node", "group"
+	BroadcastConsumerGroups = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ws_broadcast_consumer_groups",
+		Help: "Kafka consumer groups used by the hub to receive every broadcast event",
+	}, []string{"node", "group"})
+
 	// --- Throughput ---
 
 	// Track volume of messages. Labels: "type" (chat, typing, presence)
@@ -43,6 +49,42 @@ var (
 		Help: "Number of typing events dropped/merged to save bandwidth",
 	})
 
+	// Session resume outcomes. Labels: "result" (replayed, resync, expired)
+	SessionResumes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_session_resumes_total",
+		Help: "Total room resumes by outcome of the missed events replay",
+	}, []string{"result"})
+
+	// Slow client backpressure outcomes. Labels: "outcome" (shed, resync, disconnect, recovered)
+	SlowClients = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_slow_client_total",
+		Help: "Total backpressure actions taken for the clients falling behind",
+	}, []string{"outcome"})
+
+	// Notification stream. Labels: "event", "result" (delivered, dropped)
+	Notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_notifications_total",
+		Help: "Total per-user notification events sent to the client sessions",
+	}, []string{"event", "result"})
+
+	// Rooms revoked from the live clients. Labels: "reason" (membership_revoked, conversation_removed)
+	RoomRevocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_room_revocations_total",
+		Help: "Total client room subscriptions revoked after the membership changes",
+	}, []string{"reason"})
+
+	// Connection token lifecycle. Labels: "outcome" (refreshed, refresh_failed, expired, revoked)
+	AuthEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_auth_events_total",
+		Help: "Total token refreshes and auth closes of the connections",
+	}, []string{"outcome"})
+
+	// Rejected upgrades. Labels: "reason" (origin, node_full, user_limit)
+	AdmissionRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_admission_rejections_total",
+		Help: "Total websocket upgrades rejected by the admission control",
+	}, []string{"reason"})
+
 	// --- Latency (Performance) ---
 
 	// How long it takes to fan-out a message to a room.
@@ -19,6 +19,8 @@ type contextKey string
 
 const userIDKey contextKey = "userID"
 const userEmailKey contextKey = "email"
+const authSessionIDKey contextKey = "authSessionID"
+const tokenExpiresAtKey contextKey = "tokenExpiresAt"
 
 func GrpcAuthMiddleware() gin.HandlerFunc {
 
@@ -74,6 +76,8 @@ func GrpcAuthMiddleware() gin.HandlerFunc {
 		// Store in context so handlers can access it
 		ctx.Set(userIDKey, userID)
 		ctx.Set(userEmailKey, email)
+		ctx.Set(authSessionIDKey, snowflake.ID(resp.SessionID))
+		ctx.Set(tokenExpiresAtKey, resp.ExpiresAt)
 
 		ctx.Next()
 	}
@@ -98,3 +102,10 @@ func GetWsContextUserIDEmail(ctx *gin.Context) (userID snowflake.ID, email strin
 	}
 	return 0, "", false
 }
+
+// Auth session and the unix expiry of the validated token; zero if token has none
+func GetWsContextToken(ctx *gin.Context) (sessionID snowflake.ID, expiresAt int64) {
+	sessionID, _ = GetWsContextValue[snowflake.ID](ctx, authSessionIDKey)
+	expiresAt, _ = GetWsContextValue[int64](ctx, tokenExpiresAtKey)
+	return sessionID, expiresAt
+}
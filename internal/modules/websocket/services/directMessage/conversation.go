@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	modelsLib "github.com/himanshu3889/discore-backend/base/lib/models"
 	"github.com/himanshu3889/discore-backend/base/models"
 	directmessage "github.com/himanshu3889/discore-backend/base/store/directMessage"
 	"github.com/himanshu3889/discore-backend/base/utils"
@@ -23,8 +24,12 @@ func SendDirectMessage(rawMessage *json.RawMessage, userID snowflake.ID) (*model
 	msgID := utils.GenerateSnowflakeID()
 	msg.ID = msgID
 	msg.UserID = userID
+	modelsLib.SanitizeIncomingDirectMessage(&msg)
 
 	appErr := directmessage.CreateDirectMessage(ctx, &msg)
+	if appErr != nil {
+		return nil, errors.New(appErr.Message)
+	}
 
-	return &msg, errors.New(appErr.Message)
+	return &msg, nil
 }
@@ -69,6 +69,8 @@ type ValidateAccessTokenResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserID        int64                  `protobuf:"varint,1,opt,name=userID,proto3" json:"userID,omitempty"`
 	Email         string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	SessionID     int64                  `protobuf:"varint,3,opt,name=sessionID,proto3" json:"sessionID,omitempty"`
+	ExpiresAt     int64                  `protobuf:"varint,4,opt,name=expiresAt,proto3" json:"expiresAt,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -117,16 +119,32 @@ func (x *ValidateAccessTokenResponse) GetEmail() string {
 	return ""
 }
 
+func (x *ValidateAccessTokenResponse) GetSessionID() int64 {
+	if x != nil {
+		return x.SessionID
+	}
+	return 0
+}
+
+func (x *ValidateAccessTokenResponse) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
 var File_protos_auth_auth_proto protoreflect.FileDescriptor
 
 const file_protos_auth_auth_proto_rawDesc = "" +
 	"\n" +
 	"\x16protos/auth/auth.proto\x12\x04auth\"2\n" +
 	"\x1aValidateAccessTokenRequest\x12\x14\n" +
-	"\x05token\x18\x01 \x01(\tR\x05token\"K\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"\x87\x01\n" +
 	"\x1bValidateAccessTokenResponse\x12\x16\n" +
 	"\x06userID\x18\x01 \x01(\x03R\x06userID\x12\x14\n" +
-	"\x05email\x18\x02 \x01(\tR\x05email2i\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x1c\n" +
+	"\tsessionID\x18\x03 \x01(\x03R\tsessionID\x12\x1c\n" +
+	"\texpiresAt\x18\x04 \x01(\x03R\texpiresAt2i\n" +
 	"\vAuthService\x12Z\n" +
 	"\x13ValidateAccessToken\x12 .auth.ValidateAccessTokenRequest\x1a!.auth.ValidateAccessTokenResponseB5Z3github.com/himanshu3889/discore-backend/protos/authb\x06proto3"
 
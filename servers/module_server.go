@@ -143,6 +143,10 @@ func (s *ModuleServer) Start() error {
 }
 
 func (s *ModuleServer) Shutdown(ctx context.Context) error {
+	// Drain the websockets first; hijacked connections are not closed by the http server
+	if err := websocketApp.ShutdownHub(ctx); err != nil {
+		logrus.WithError(err).Warn("Websocket hub drain incomplete")
+	}
 	return s.server.Shutdown(ctx)
 }
 
@@ -170,7 +174,7 @@ func (s *ModuleServer) Run() {
 
 	logrus.Info("Shutting down module server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := s.Shutdown(ctx); err != nil {